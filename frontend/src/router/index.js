@@ -8,10 +8,17 @@ import Team from "../views/Team.vue";
 import Ranking from "../views/Ranking.vue";
 import PasswordResetRequest from "../views/PasswordResetRequest.vue";
 import PasswordReset from "../views/PasswordReset.vue";
+import VerifyEmail from "../views/VerifyEmail.vue";
+import Account from "../views/Account.vue";
 
 import Admin from "../views/Admin.vue";
 import AdminConfig from "../views/admin/Config.vue";
+import AdminDashboard from "../views/admin/Dashboard.vue";
 import AdminChallenges from "../views/admin/Challenges.vue";
+import AdminUsers from "../views/admin/Users.vue";
+import AdminTeams from "../views/admin/Teams.vue";
+import AdminSubmissions from "../views/admin/Submissions.vue";
+import AdminAuditLogs from "../views/admin/AuditLogs.vue";
 
 Vue.use(VueRouter);
 
@@ -41,6 +48,16 @@ const routes = [
     name: "Reset",
     component: PasswordReset
   },
+  {
+    path: "/verify-email",
+    name: "VerifyEmail",
+    component: VerifyEmail
+  },
+  {
+    path: "/account",
+    name: "Account",
+    component: Account
+  },
   {
     path: "/challenges",
     name: "Challenges",
@@ -64,6 +81,10 @@ const routes = [
     path: "/admin/",
     component: Admin,
     children: [
+      {
+        path: "dashboard",
+        component: AdminDashboard
+      },
       {
         path: "config",
         component: AdminConfig
@@ -71,6 +92,22 @@ const routes = [
       {
         path: "challenges",
         component: AdminChallenges
+      },
+      {
+        path: "users",
+        component: AdminUsers
+      },
+      {
+        path: "teams",
+        component: AdminTeams
+      },
+      {
+        path: "submissions",
+        component: AdminSubmissions
+      },
+      {
+        path: "audit-logs",
+        component: AdminAuditLogs
       }
     ]
   }
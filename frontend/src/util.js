@@ -18,3 +18,20 @@ export const showMessage = (that, m) => {
     queue: false
   });
 };
+
+// downloadBlob saves the blob as a file
+export const downloadBlob = (name, blob) => {
+  const a = document.createElement("a");
+  a.href = URL.createObjectURL(blob);
+  a.download = name;
+  a.click();
+  URL.revokeObjectURL(a.href);
+};
+
+// downloadJSON saves the data as a JSON file
+export const downloadJSON = (name, data) => {
+  const blob = new Blob([JSON.stringify(data, null, 2)], {
+    type: "application/json"
+  });
+  downloadBlob(name, blob);
+};
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// A bundle is a tar.gz archive which contains manifest.json and the attachment files.
+// bump bundleVersion when the manifest changes incompatibly.
+const (
+	bundleVersion      = 1
+	bundleManifestName = "manifest.json"
+)
+
+type BundleManifest struct {
+	Version    int               `json:"version"`
+	ExportedAt int64             `json:"exported_at"`
+	Challenges []BundleChallenge `json:"challenges"`
+}
+
+type BundleChallenge struct {
+	Name          string             `json:"name"`
+	Description   string             `json:"description"`
+	Flag          string             `json:"flag"`
+	Category      string             `json:"category"`
+	Difficulty    string             `json:"difficulty"`
+	Tags          []string           `json:"tags"`
+	Author        string             `json:"author"`
+	BaseScore     int                `json:"base_score"`
+	IsDynamic     bool               `json:"is_dynamic"`
+	IsQuestionary bool               `json:"is_questionary"`
+	Host          *string            `json:"host"`
+	Port          *string            `json:"port"`
+	Attachments   []BundleAttachment `json:"attachments"`
+	Statistics    *BundleStatistics  `json:"statistics,omitempty"`
+}
+
+type BundleAttachment struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+}
+
+// BundleStatistics is only for information. it is not imported
+type BundleStatistics struct {
+	Solves        int    `json:"solves"`
+	FirstSolvedAt *int64 `json:"first_solved_at"`
+}
+
+func (c *BundleChallenge) challenge() Challenge {
+	return Challenge{
+		Name:          c.Name,
+		Description:   c.Description,
+		Flag:          c.Flag,
+		Category:      c.Category,
+		Tags:          c.Tags,
+		Author:        c.Author,
+		BaseScore:     c.BaseScore,
+		Difficulty:    c.Difficulty,
+		IsDynamic:     c.IsDynamic,
+		IsQuestionary: c.IsQuestionary,
+		Host:          c.Host,
+		Port:          c.Port,
+	}
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n  %s export [OPTIONS] [CHALLENGES]\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "output bundle path")
+	stats := fs.Bool("stats", false, "include solve statistics")
+	fs.Parse(args)
+
+	if *output == "" {
+		fs.Usage()
+		return nil
+	}
+
+	dbdsn := os.Getenv("DBDSN")
+	if dbdsn == "" {
+		return fmt.Errorf("Environmental variable DBDSN is requried")
+	}
+	repo, err := repository.New(dbdsn, nil)
+	if err != nil {
+		return err
+	}
+
+	chals, err := repo.ListAllChallenges(false)
+	if err != nil {
+		return err
+	}
+	chals = filterChallenges(chals, fs.Args())
+
+	buf, err := exportBundle(chals, repo, *stats)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*output, buf, 0644); err != nil {
+		return err
+	}
+	log.Printf("EXPORT %d challenge(s) to %s\n", len(chals), *output)
+	return nil
+}
+
+func filterChallenges(chals []*model.Challenge, names []string) []*model.Challenge {
+	if len(names) == 0 {
+		return chals
+	}
+	nameMap := make(map[string]struct{})
+	for _, name := range names {
+		nameMap[name] = struct{}{}
+	}
+	filtered := make([]*model.Challenge, 0, len(names))
+	for _, chal := range chals {
+		if _, ok := nameMap[chal.Name]; ok {
+			filtered = append(filtered, chal)
+		}
+	}
+	return filtered
+}
+
+func exportBundle(chals []*model.Challenge, repo repository.Repository, withStats bool) ([]byte, error) {
+	manifest := BundleManifest{
+		Version:    bundleVersion,
+		ExportedAt: time.Now().Unix(),
+		Challenges: make([]BundleChallenge, 0, len(chals)),
+	}
+	files := make(map[string][]byte)
+
+	for _, chal := range chals {
+		c := BundleChallenge{
+			Name:          chal.Name,
+			Description:   chal.Description,
+			Flag:          chal.Flag,
+			Category:      chal.Category,
+			Difficulty:    chal.Difficulty,
+			Tags:          chal.Tags,
+			Author:        chal.Author,
+			BaseScore:     chal.BaseScore,
+			IsDynamic:     chal.IsDynamic,
+			IsQuestionary: chal.IsQuestionary,
+			Host:          chal.Host,
+			Port:          chal.Port,
+			Attachments:   make([]BundleAttachment, 0, len(chal.Attachments)),
+		}
+
+		for _, u := range chal.Attachments {
+			data, err := download(u)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", chal.Name, err)
+			}
+			filename := path.Base(u)
+			p := path.Join("attachments", fmt.Sprintf("%d", chal.ID), filename)
+			files[p] = data
+			c.Attachments = append(c.Attachments, BundleAttachment{
+				Filename: filename,
+				Path:     p,
+			})
+		}
+
+		if withStats {
+			submissions, err := repo.ListValidSubmission(chal.ID)
+			if err != nil {
+				return nil, err
+			}
+			c.Statistics = &BundleStatistics{
+				Solves: len(submissions),
+			}
+			for _, s := range submissions {
+				if c.Statistics.FirstSolvedAt == nil || s.SubmittedAt < *c.Statistics.FirstSolvedAt {
+					t := s.SubmittedAt
+					c.Statistics.FirstSolvedAt = &t
+				}
+			}
+		}
+		manifest.Challenges = append(manifest.Challenges, c)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	gz.Header = gzip.Header{OS: 255}
+	tw := tar.NewWriter(gz)
+
+	if err := writeBundleFile(tw, bundleManifestName, manifestData); err != nil {
+		return nil, err
+	}
+	for _, c := range manifest.Challenges {
+		for _, a := range c.Attachments {
+			if err := writeBundleFile(tw, a.Path, files[a.Path]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeBundleFile(tw *tar.Writer, name string, data []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  archiveModTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+func download(u string) ([]byte, error) {
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !(200 <= resp.StatusCode && resp.StatusCode < 300) {
+		return nil, fmt.Errorf("failed to download %s: %s", u, resp.Status)
+	}
+	return data, nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n  %s import [OPTIONS] BUNDLE [CHALLENGES]\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	transfersh := fs.String("transfersh", "", "transfer.sh upload url")
+	s3bucket := fs.String("bucket", "", "S3 Bucket Name")
+	s3region := fs.String("region", "", "S3 Region Name")
+	fs.Parse(args)
+
+	uploader := newUploader(*transfersh, *s3bucket, *s3region)
+	if fs.NArg() == 0 || uploader == nil {
+		fs.Usage()
+		return nil
+	}
+
+	dbdsn := os.Getenv("DBDSN")
+	if dbdsn == "" {
+		return fmt.Errorf("Environmental variable DBDSN is requried")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, files, err := readBundle(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	repo, err := repository.New(dbdsn, nil)
+	if err != nil {
+		return err
+	}
+
+	nameMap := make(map[string]struct{})
+	for _, name := range fs.Args()[1:] {
+		nameMap[name] = struct{}{}
+	}
+
+	failed := 0
+	for _, c := range manifest.Challenges {
+		if _, ok := nameMap[c.Name]; len(nameMap) != 0 && !ok {
+			continue
+		}
+		if err := importChallenge(c, files, repo, uploader); err != nil {
+			log.Printf("FAIL %s: %v\n", c.Name, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to import %d challenge(s)", failed)
+	}
+	return nil
+}
+
+func readBundle(r io.Reader) (*BundleManifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[path.Clean(header.Name)] = data
+	}
+
+	manifestData, ok := files[bundleManifestName]
+	if !ok {
+		return nil, nil, errors.New("manifest.json not found. this is not a challenge bundle")
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, nil, err
+	}
+	if manifest.Version != bundleVersion {
+		return nil, nil, fmt.Errorf("unsupported bundle version %d (supported: %d)", manifest.Version, bundleVersion)
+	}
+	return &manifest, files, nil
+}
+
+// lintBundleChallenge validates the challenge with the same rules as challenges.yaml,
+// because a bundle may be written or edited by hand
+func lintBundleChallenge(c BundleChallenge) error {
+	fields := map[string]interface{}{
+		"description":    c.Description,
+		"flag":           c.Flag,
+		"category":       c.Category,
+		"tags":           c.Tags,
+		"author":         c.Author,
+		"base_score":     c.BaseScore,
+		"difficulty":     c.Difficulty,
+		"is_dynamic":     c.IsDynamic,
+		"is_questionary": c.IsQuestionary,
+	}
+	if c.Tags == nil {
+		delete(fields, "tags")
+	}
+	if c.Host != nil {
+		fields["host"] = *c.Host
+	}
+	if c.Port != nil {
+		fields["port"] = *c.Port
+	}
+	data, err := yaml.Marshal(map[string]interface{}{
+		"challenges": map[string]interface{}{c.Name: fields},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, lintErrors, err := parseChallenges(data)
+	if err != nil {
+		return err
+	}
+	if len(lintErrors) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(lintErrors))
+	for _, e := range lintErrors {
+		messages = append(messages, e.Message)
+	}
+	return errors.New(strings.Join(messages, ", "))
+}
+
+// importChallenge registers the challenge in the same way as registerChallenge
+func importChallenge(c BundleChallenge, files map[string][]byte, repo repository.Repository, uploader Uploader) error {
+	if err := lintBundleChallenge(c); err != nil {
+		return err
+	}
+	for _, a := range c.Attachments {
+		if _, ok := files[path.Clean(a.Path)]; !ok {
+			return fmt.Errorf("attachment %s is not in the bundle", a.Path)
+		}
+	}
+
+	id, err := saveChallenge(c.challenge(), repo)
+	if err != nil {
+		return err
+	}
+
+	uploaded, err := uploadedAttachments(id, repo)
+	if err != nil {
+		return err
+	}
+	for _, a := range c.Attachments {
+		data := files[path.Clean(a.Path)]
+		if err := uploadAttachment(id, path.Base(a.Filename), data, uploaded, repo, uploader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
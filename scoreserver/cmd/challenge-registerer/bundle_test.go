@@ -0,0 +1,164 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	attachment := []byte("distfiles")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(attachment)
+	}))
+	defer ts.Close()
+
+	host, port := "localhost", "9000"
+	chals := []*model.Challenge{
+		{
+			ID:          1,
+			Name:        "pwn",
+			Flag:        "zer0pts{pwn}",
+			Description: "nc {{.Host}} {{.Port}}",
+			Category:    "pwn",
+			Difficulty:  "easy",
+			Tags:        []string{"heap"},
+			Attachments: []string{ts.URL + "/pwn_0123.tar.gz"},
+			Author:      "zer0pts",
+			BaseScore:   500,
+			IsDynamic:   true,
+			Host:        &host,
+			Port:        &port,
+		},
+	}
+	data, err := exportBundle(chals, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, files, err := readBundle(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Challenges) != 1 {
+		t.Fatalf("expected 1 challenge, but got %d", len(manifest.Challenges))
+	}
+	c := manifest.Challenges[0]
+	chal := c.challenge()
+	expected := Challenge{
+		Name:        "pwn",
+		Description: "nc {{.Host}} {{.Port}}",
+		Flag:        "zer0pts{pwn}",
+		Category:    "pwn",
+		Tags:        []string{"heap"},
+		Author:      "zer0pts",
+		BaseScore:   500,
+		Difficulty:  "easy",
+		IsDynamic:   true,
+		Host:        &host,
+		Port:        &port,
+	}
+	if !reflect.DeepEqual(chal, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, chal)
+	}
+	if len(c.Attachments) != 1 || c.Attachments[0].Filename != "pwn_0123.tar.gz" {
+		t.Fatalf("wrong attachments: %v", c.Attachments)
+	}
+	if !bytes.Equal(files[c.Attachments[0].Path], attachment) {
+		t.Errorf("wrong attachment data: %q", files[c.Attachments[0].Path])
+	}
+	if err := lintBundleChallenge(c); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadBundleVersion(t *testing.T) {
+	manifest, err := json.Marshal(BundleManifest{Version: bundleVersion + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	if err := writeBundleFile(tw, bundleManifestName, manifest); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gz.Close()
+
+	if _, _, err := readBundle(buf); err == nil || !strings.Contains(err.Error(), "unsupported bundle version") {
+		t.Errorf("a bundle of another version should be rejected, err: %v", err)
+	}
+}
+
+func TestImportChallengeMissingAttachment(t *testing.T) {
+	c := BundleChallenge{
+		Name:        "missing",
+		Description: "no attachment",
+		Flag:        "zer0pts{missing}",
+		Category:    "misc",
+		Author:      "zer0pts",
+		BaseScore:   100,
+		Difficulty:  "easy",
+		Attachments: []BundleAttachment{{Filename: "missing.tar.gz", Path: "attachments/1/missing.tar.gz"}},
+	}
+	// the challenge must not be saved, so the repository is not used
+	err := importChallenge(c, map[string][]byte{}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "is not in the bundle") {
+		t.Errorf("a missing attachment should be rejected, err: %v", err)
+	}
+}
+
+func TestLintBundleChallenge(t *testing.T) {
+	port, badPort, empty := "9000", "ninethousand", " "
+	valid := func() BundleChallenge {
+		return BundleChallenge{
+			Name:        "valid",
+			Description: "nc localhost {{.Port}}",
+			Flag:        "zer0pts{valid}",
+			Category:    "pwn",
+			Author:      "zer0pts",
+			BaseScore:   500,
+			Difficulty:  "easy",
+			Port:        &port,
+		}
+	}
+
+	cases := []struct {
+		name    string
+		modify  func(c *BundleChallenge)
+		message string
+	}{
+		{"valid", func(c *BundleChallenge) {}, ""},
+		{"name", func(c *BundleChallenge) { c.Name = "" }, "challenge name is required"},
+		{"flag", func(c *BundleChallenge) { c.Flag = "" }, "flag must not be empty"},
+		{"category", func(c *BundleChallenge) { c.Category = "" }, "category must not be empty"},
+		{"difficulty", func(c *BundleChallenge) { c.Difficulty = "lunatic" }, "difficulty must be one of"},
+		{"score", func(c *BundleChallenge) { c.BaseScore = 0 }, "base_score must be positive"},
+		{"host", func(c *BundleChallenge) { c.Host = &empty }, "host must not be empty"},
+		{"port", func(c *BundleChallenge) { c.Port = &badPort }, "port must be a number"},
+		{"description", func(c *BundleChallenge) { c.Port = nil }, "description:"},
+	}
+	for _, tc := range cases {
+		c := valid()
+		tc.modify(&c)
+		err := lintBundleChallenge(c)
+		if tc.message == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("%s: expected %q, but got %v", tc.name, tc.message, err)
+		}
+	}
+}
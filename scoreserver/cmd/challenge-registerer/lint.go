@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// limits come from database/schema.sql
+const (
+	challengeNameMaxLength = 64
+	flagMaxLength          = 256
+	tagMaxLength           = 256
+)
+
+var (
+	difficulties = map[string]struct{}{
+		"easy":   struct{}{},
+		"medium": struct{}{},
+		"hard":   struct{}{},
+	}
+	challengeKeys = map[string]struct{}{
+		"description":    struct{}{},
+		"flag":           struct{}{},
+		"category":       struct{}{},
+		"tags":           struct{}{},
+		"author":         struct{}{},
+		"base_score":     struct{}{},
+		"difficulty":     struct{}{},
+		"is_dynamic":     struct{}{},
+		"is_questionary": struct{}{},
+		"host":           struct{}{},
+		"port":           struct{}{},
+	}
+	requiredChallengeKeys = []string{"description", "flag", "category", "author", "base_score", "difficulty"}
+)
+
+type LintError struct {
+	Line      int
+	Column    int
+	Challenge string
+	Message   string
+}
+
+func (e LintError) String() string {
+	if e.Challenge == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: [%s] %s", e.Line, e.Column, e.Challenge, e.Message)
+}
+
+type linter struct {
+	errors []LintError
+	flags  map[string]string
+}
+
+func (l *linter) add(node *yaml.Node, challenge, format string, args ...interface{}) {
+	l.errors = append(l.errors, LintError{
+		Line:      node.Line,
+		Column:    node.Column,
+		Challenge: challenge,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// parseChallenges parses challenges.yaml and validates every challenge in it.
+// lint errors do not stop the parsing so that all problems are reported at once.
+func parseChallenges(data []byte) (*Challenges, []LintError, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+
+	l := &linter{
+		errors: make([]LintError, 0),
+		flags:  make(map[string]string),
+	}
+	chals := &Challenges{
+		Challenges: make(map[string]Challenge),
+	}
+
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		l.add(&root, "", "top level must be a mapping")
+		return chals, l.errors, nil
+	}
+	doc := root.Content[0]
+
+	chalsNode := mappingValue(doc, "challenges")
+	if chalsNode == nil {
+		l.add(doc, "", "challenges is required")
+		return chals, l.errors, nil
+	}
+	chalsNode = resolveAlias(chalsNode)
+	if chalsNode.Kind != yaml.MappingNode {
+		l.add(chalsNode, "", "challenges must be a mapping from challenge name to challenge")
+		return chals, l.errors, nil
+	}
+
+	for i := 0; i+1 < len(chalsNode.Content); i += 2 {
+		keyNode, valueNode := chalsNode.Content[i], resolveAlias(chalsNode.Content[i+1])
+		name := keyNode.Value
+
+		if _, ok := chals.Challenges[name]; ok {
+			l.add(keyNode, name, "challenge is defined more than once")
+			continue
+		}
+		chal, ok := l.lintChallenge(name, keyNode, valueNode)
+		if ok {
+			chals.Challenges[name] = chal
+		}
+	}
+
+	sort.SliceStable(l.errors, func(i, j int) bool {
+		if l.errors[i].Line == l.errors[j].Line {
+			return l.errors[i].Column < l.errors[j].Column
+		}
+		return l.errors[i].Line < l.errors[j].Line
+	})
+	return chals, l.errors, nil
+}
+
+func (l *linter) lintChallenge(name string, keyNode, node *yaml.Node) (Challenge, bool) {
+	var chal Challenge
+	if name == "" {
+		l.add(keyNode, name, "challenge name is required")
+	}
+	if len(name) > challengeNameMaxLength {
+		l.add(keyNode, name, "challenge name must be at most %d bytes", challengeNameMaxLength)
+	}
+	if node.Kind != yaml.MappingNode {
+		l.add(node, name, "challenge must be a mapping")
+		return chal, false
+	}
+
+	fields := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k := node.Content[i]
+		if _, ok := challengeKeys[k.Value]; !ok {
+			l.add(k, name, "unknown key %q", k.Value)
+			continue
+		}
+		if _, ok := fields[k.Value]; ok {
+			l.add(k, name, "%s is defined more than once", k.Value)
+			continue
+		}
+		fields[k.Value] = resolveAlias(node.Content[i+1])
+	}
+	for _, k := range requiredChallengeKeys {
+		if _, ok := fields[k]; !ok {
+			l.add(keyNode, name, "%s is required", k)
+		}
+	}
+
+	// type checks are left to the decoder
+	if err := node.Decode(&chal); err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, e := range typeErr.Errors {
+				l.add(node, name, "%s", e)
+			}
+		} else {
+			l.add(node, name, "%v", err)
+		}
+		return chal, false
+	}
+	chal.Name = name
+
+	if n, ok := fields["flag"]; ok {
+		if chal.Flag == "" {
+			l.add(n, name, "flag must not be empty")
+		} else if strings.TrimSpace(chal.Flag) != chal.Flag {
+			l.add(n, name, "flag must not start or end with spaces")
+		}
+		if len(chal.Flag) > flagMaxLength {
+			l.add(n, name, "flag must be at most %d bytes", flagMaxLength)
+		}
+		if other, ok := l.flags[chal.Flag]; ok && chal.Flag != "" {
+			l.add(n, name, "flag is the same as challenge %q", other)
+		} else {
+			l.flags[chal.Flag] = name
+		}
+	}
+
+	if n, ok := fields["difficulty"]; ok {
+		if _, ok := difficulties[chal.Difficulty]; !ok && !(chal.IsQuestionary && chal.Difficulty == "questionary") {
+			l.add(n, name, "difficulty must be one of easy, medium or hard, but %q", chal.Difficulty)
+		}
+	}
+
+	if n, ok := fields["base_score"]; ok && chal.BaseScore <= 0 {
+		l.add(n, name, "base_score must be positive")
+	}
+
+	for _, k := range []string{"category", "author"} {
+		if n, ok := fields[k]; ok && strings.TrimSpace(n.Value) == "" {
+			l.add(n, name, "%s must not be empty", k)
+		}
+	}
+
+	if n, ok := fields["tags"]; ok {
+		seen := make(map[string]struct{})
+		for i, t := range chal.Tags {
+			tagNode := n
+			if i < len(n.Content) {
+				tagNode = n.Content[i]
+			}
+			if t == "" {
+				l.add(tagNode, name, "tag must not be empty")
+			}
+			if len(t) > tagMaxLength {
+				l.add(tagNode, name, "tag must be at most %d bytes", tagMaxLength)
+			}
+			if _, ok := seen[t]; ok {
+				l.add(tagNode, name, "tag %q is duplicated", t)
+			}
+			seen[t] = struct{}{}
+		}
+	}
+
+	if n, ok := fields["host"]; ok && chal.Host != nil && strings.TrimSpace(*chal.Host) == "" {
+		l.add(n, name, "host must not be empty")
+	}
+	if n, ok := fields["port"]; ok && chal.Port != nil {
+		if port, err := strconv.Atoi(*chal.Port); err != nil || port < 1 || port > 65535 {
+			l.add(n, name, "port must be a number from 1 to 65535, but %q", *chal.Port)
+		}
+	}
+
+	if n, ok := fields["description"]; ok {
+		if err := checkDescription(chal); err != nil {
+			l.add(n, name, "description: %v", err)
+		}
+	}
+
+	return chal, true
+}
+
+// checkDescription renders the description with only the values which are actually set,
+// so that referring {{.Port}} without port is an error
+func checkDescription(chal Challenge) error {
+	t, err := template.New(chal.Name).Option("missingkey=error").Parse(chal.Description)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{})
+	if chal.Host != nil {
+		values["Host"] = *chal.Host
+	}
+	if chal.Port != nil {
+		values["Port"] = *chal.Port
+	}
+	return t.Execute(ioutil.Discard, values)
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// loadChallenges reads challenges.yaml in dir and refuses it when there are lint errors
+func loadChallenges(dir string) (*Challenges, error) {
+	path := filepath.Join(dir, "challenges.yaml")
+	if stat, err := os.Stat(path); err != nil || !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s not found or not a regular file", path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	chals, lintErrors, err := parseChallenges(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(lintErrors) > 0 {
+		printLintErrors(path, lintErrors)
+		return nil, fmt.Errorf("%s has %d problem(s). run `%s lint` for the details", path, len(lintErrors), filepath.Base(os.Args[0]))
+	}
+	return chals, nil
+}
+
+func printLintErrors(path string, lintErrors []LintError) {
+	buf := new(bytes.Buffer)
+	for _, e := range lintErrors {
+		fmt.Fprintf(buf, "%s:%s\n", path, e)
+	}
+	os.Stderr.Write(buf.Bytes())
+}
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n  %s lint [OPTIONS]\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	dir := fs.String("dir", "", "challenges directory path")
+	fs.Parse(args)
+
+	if *dir == "" {
+		fs.Usage()
+		return nil
+	}
+
+	chals, err := loadChallenges(*dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d challenge(s) OK\n", len(chals.Challenges))
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseChallenges(t *testing.T) {
+	data := `
+servers:
+  ?: &pwn_host localhost
+challenges:
+  "ok":
+    description: 'nc {{.Host}} {{.Port}}'
+    flag: zer0pts{ok}
+    category: pwn
+    tags: [heap]
+    author: zer0pts
+    base_score: 500
+    difficulty: easy
+    is_dynamic: true
+    host: *pwn_host
+    port: 9000
+  "Questionary":
+    description: "thank you"
+    flag: zer0pts{thank_you}
+    category: questionary
+    author: zer0pts
+    base_score: 100
+    difficulty: questionary
+    is_questionary: true
+`
+	chals, lintErrors, err := parseChallenges([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lintErrors) != 0 {
+		t.Errorf("unexpected lint errors: %v", lintErrors)
+	}
+	chal, ok := chals.Challenges["ok"]
+	if !ok {
+		t.Fatal("challenge not parsed")
+	}
+	if chal.Name != "ok" || chal.Host == nil || *chal.Host != "localhost" || chal.Port == nil || *chal.Port != "9000" {
+		t.Errorf("wrong challenge: %+v", chal)
+	}
+}
+
+func TestParseChallengesLintErrors(t *testing.T) {
+	data := `challenges:
+  "no flag":
+    description: 'nc {{.Host}} {{.Port}}'
+    category: pwn
+    author: zer0pts
+    base_score: 0
+    difficulty: insane
+    host: localhost
+  "dup1":
+    description: "a"
+    flag: zer0pts{dup}
+    category: web
+    author: zer0pts
+    base_score: 100
+    difficulty: easy
+    tags: [xss, xss]
+  "dup2":
+    description: "b"
+    flag: zer0pts{dup}
+    category: web
+    author: zer0pts
+    base_score: 100
+    difficulty: hard
+    base_scroe: 100
+`
+	_, lintErrors, err := parseChallenges([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		line    int
+		message string
+	}{
+		{2, "flag is required"},
+		{3, "description"},
+		{6, "base_score must be positive"},
+		{7, "difficulty must be one of"},
+		{16, "tag \"xss\" is duplicated"},
+		{19, "flag is the same as challenge \"dup1\""},
+		{24, "unknown key \"base_scroe\""},
+	}
+	if len(lintErrors) != len(expected) {
+		t.Fatalf("expected %d errors, but got %v", len(expected), lintErrors)
+	}
+	for i, e := range expected {
+		if lintErrors[i].Line != e.line || !strings.Contains(lintErrors[i].Message, e.message) {
+			t.Errorf("expected %d: %s, but got %v", e.line, e.message, lintErrors[i])
+		}
+	}
+}
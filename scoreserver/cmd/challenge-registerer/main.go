@@ -15,18 +15,23 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/repository"
-	"gopkg.in/yaml.v2"
 )
 
+// archiveModTime is the timestamp written for every entry of distfiles archives
+var archiveModTime = time.Unix(0, 0)
+
 type Uploader interface {
 	Upload(name string, data []byte) (string, error)
 }
@@ -85,6 +90,26 @@ func (s *s3Uploader) Upload(name string, data []byte) (string, error) {
 	return res.Location, nil
 }
 
+func newUploader(transfersh, s3bucket, s3region string) Uploader {
+	var uploader Uploader
+	if transfersh != "" {
+		uploader = &transfershUploader{
+			url: transfersh,
+		}
+	}
+	if s3bucket != "" && s3region != "" {
+		sess := session.Must(session.NewSession(&aws.Config{
+			Region: aws.String(s3region),
+		}))
+
+		uploader = &s3Uploader{
+			uploader: s3manager.NewUploader(sess),
+			bucket:   s3bucket,
+		}
+	}
+	return uploader
+}
+
 type Challenge struct {
 	Name          string
 	Description   string   `yaml:"description"`
@@ -111,7 +136,7 @@ func run() error {
 	}
 
 	flag.Usage = func() {
-		fmt.Printf("Usage:\n  %s [OPTIONS] [CHALLENGES]\n\nOptions:\n", os.Args[0])
+		fmt.Printf("Usage:\n  %s [OPTIONS] [CHALLENGES]\n  %s lint|export|import [OPTIONS]\n\nOptions:\n", os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 	}
 
@@ -121,53 +146,29 @@ func run() error {
 	s3region := flag.String("region", "", "S3 Region Name")
 
 	flag.Parse()
-	var uploader Uploader
-
-	if transfersh != nil && *transfersh != "" {
-		uploader = &transfershUploader{
-			url: *transfersh,
-		}
-	}
-	if s3bucket != nil && *s3bucket != "" && s3region != nil && *s3region != "" {
-		sess := session.Must(session.NewSession(&aws.Config{
-			Region: aws.String(*s3region),
-		}))
-
-		uploader = &s3Uploader{
-			uploader: s3manager.NewUploader(sess),
-			bucket:   *s3bucket,
-		}
-	}
+	uploader := newUploader(*transfersh, *s3bucket, *s3region)
 
 	if dir == nil || *dir == "" || uploader == nil {
 		flag.Usage()
 		return nil
 	}
 
-	repo, err := repository.New(dbdsn, nil)
+	chals, err := loadChallenges(*dir)
 	if err != nil {
 		return err
 	}
 
-	challenges := filepath.Join(*dir, "challenges.yaml")
-	if stat, err := os.Stat(challenges); err != nil || !stat.Mode().IsRegular() {
-		return fmt.Errorf("%s not found or not a regular file", challenges)
-	}
-
-	chals := Challenges{}
-	data, err := ioutil.ReadFile(challenges)
+	repo, err := repository.New(dbdsn, nil)
 	if err != nil {
 		return err
 	}
-	if err := yaml.Unmarshal(data, &chals); err != nil {
-		return err
-	}
 
 	chalNameMap := make(map[string]struct{})
 	for _, chal := range flag.Args() {
 		chalNameMap[chal] = struct{}{}
 	}
 
+	failed := 0
 	for name, chal := range chals.Challenges {
 		if _, ok := chalNameMap[name]; len(chalNameMap) != 0 && !ok {
 			continue
@@ -175,73 +176,202 @@ func run() error {
 		chal.Name = name
 		err := registerChallenge(*dir, chal, repo, uploader)
 		if err != nil {
-			log.Println(err)
+			log.Printf("FAIL %s: %v\n", name, err)
+			failed++
 		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("failed to register %d challenge(s)", failed)
+	}
 	return nil
 }
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	subcommand := ""
+	if len(os.Args) > 1 {
+		subcommand = os.Args[1]
+	}
+	switch subcommand {
+	case "lint":
+		err = runLint(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		err = run()
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// compress creates a tar.gz archive of dir.
+// The output only depends on the file names, modes and contents so that the same
+// distfiles always produce the same archive (and the same md5 based filename).
 func compress(dir string) ([]byte, error) {
+	paths := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
 	buf := new(bytes.Buffer)
 	gz := gzip.NewWriter(buf)
+	gz.Header = gzip.Header{OS: 255}
 	tw := tar.NewWriter(gz)
 
-	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		header, err := tar.FileInfoHeader(info, path)
+	for _, path := range paths {
+		if err := addArchiveEntry(tw, dir, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func addArchiveEntry(tw *tar.Writer, dir, path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(path)
 		if err != nil {
 			return err
 		}
-		header.Name, err = filepath.Rel(dir, path)
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	name, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	// normalize everything that depends on the checkout
+	header.ModTime = archiveModTime
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = ""
+	header.Gname = ""
+	if info.IsDir() || info.Mode()&0111 != 0 {
+		header.Mode = 0755
+	} else {
+		header.Mode = 0644
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return err
+	}
+	return nil
+}
+
+func registerChallenge(dir string, chal Challenge, repo repository.Repository, uploader Uploader) error {
+	id, err := saveChallenge(chal, repo)
+	if err != nil {
+		return err
+	}
+
+	uploaded, err := uploadedAttachments(id, repo)
+	if err != nil {
+		return err
+	}
+
+	// if distfiles exists then upload
+	archive := filepath.Join(dir, chal.Name, "distfiles")
+	st, err := os.Stat(archive)
+	if err == nil && st.IsDir() {
+		buf, err := compress(archive)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("failed to compress %s: %w", archive, err)
 		}
-
-		if err := tw.WriteHeader(header); err != nil {
+		filename := chal.Name + "_" + hexdigest(buf) + ".tar.gz"
+		if err := uploadAttachment(id, filename, buf, uploaded, repo, uploader); err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			f, err := os.Open(path)
+	}
+
+	archive = filepath.Join(dir, chal.Name, "distarchive")
+	st, err = os.Stat(archive)
+	if err == nil && st.IsDir() {
+		err = filepath.Walk(archive, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
-			defer f.Close()
-
-			if _, err := io.Copy(tw, f); err != nil {
+			if !info.Mode().IsRegular() || !strings.HasSuffix(path, ".tar.gz") {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
 				return err
 			}
+			filename := fmt.Sprintf("%s_%s.tar.gz", filepath.Base(info.Name()), hexdigest(data))
+			return uploadAttachment(id, filename, data, uploaded, repo, uploader)
+		})
+		if err != nil {
+			return err
 		}
-		return nil
-	})
-
-	if err := tw.Close(); err != nil {
-		return nil, err
-	}
-	if err := gz.Close(); err != nil {
-		return nil, err
 	}
 
-	return buf.Bytes(), nil
+	return nil
 }
 
-func registerChallenge(dir string, chal Challenge, repo repository.Repository, uploader Uploader) error {
+// saveChallenge registers chal or updates the challenge which has the same name
+func saveChallenge(chal Challenge, repo repository.Repository) (uint32, error) {
 	// testing description
 	t, err := template.New(chal.Name).Parse(chal.Description)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	err = t.Option("missingkey=error").Execute(ioutil.Discard, map[string]interface{}{
 		"Port": chal.Port,
 		"Host": chal.Host,
 	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	id, err := repo.FindChallengeIDByName(chal.Name)
@@ -260,7 +390,7 @@ func registerChallenge(dir string, chal Challenge, repo repository.Repository, u
 			chal.Port,
 		)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		log.Printf("UPDATE %s", chal.Name)
 	} else {
@@ -279,61 +409,46 @@ func registerChallenge(dir string, chal Challenge, repo repository.Repository, u
 			chal.Port,
 		)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		log.Printf("ADD %s\n", chal.Name)
 	}
+	return id, nil
+}
 
-	// if distfiles exists then upload
-	var buf []byte
-	archive := filepath.Join(dir, chal.Name, "distfiles")
-	st, err := os.Stat(archive)
-
-	if err == nil && st.IsDir() {
-		buf, err = compress(archive)
-		if err != nil {
-			return err
-		}
-		filename := chal.Name + "_" + hexdigest(buf) + ".tar.gz"
-		attachmentURL, err := uploader.Upload(filename, buf)
-		if err != nil {
-			return err
-		}
-		err = repo.AddAttachment(id, attachmentURL)
-		if err != nil {
-			return err
-		}
-		log.Printf("UPLOAD %s as %s\n", filename, attachmentURL)
+// uploadedAttachments returns the filenames of attachments already registered to the challenge
+func uploadedAttachments(cid uint32, repo repository.Repository) (map[string]struct{}, error) {
+	attachments, err := repo.ListAttachments(cid)
+	if err != nil {
+		return nil, err
 	}
+	uploaded := make(map[string]struct{})
+	for _, a := range attachments {
+		uploaded[path.Base(a.URL)] = struct{}{}
+	}
+	return uploaded, nil
+}
 
-	archive = filepath.Join(dir, chal.Name, "distarchive")
-	st, err = os.Stat(archive)
-	if err == nil && st.IsDir() {
-		filepath.Walk(archive, func(path string, info os.FileInfo, err error) error {
-			if info.Mode().IsRegular() && strings.HasSuffix(path, ".tar.gz") {
-				data, err := ioutil.ReadFile(path)
-				if err != nil {
-					log.Println(err)
-					return nil
-				}
-				filename := fmt.Sprintf("%s_%s.tar.gz", filepath.Base(info.Name()), hexdigest(data))
-				attachmentURL, err := uploader.Upload(filename, data)
-				if err != nil {
-					log.Println(err)
-					return nil
-				}
-				err = repo.AddAttachment(id, attachmentURL)
-				if err != nil {
-					log.Println(err)
-					return nil
-				}
-				log.Printf("UPLOAD %s as %s\n", info.Name(), attachmentURL)
-				return nil
-			}
-			return nil
-		})
+// uploadAttachment uploads data unless the challenge already has an attachment with the same filename.
+// filenames contain the md5 of the data, so the same name means the same content.
+func uploadAttachment(cid uint32, filename string, data []byte, uploaded map[string]struct{}, repo repository.Repository, uploader Uploader) error {
+	if _, ok := uploaded[filename]; ok {
+		log.Printf("SKIP %s (already uploaded)\n", filename)
+		return nil
 	}
 
+	attachmentURL, err := uploader.Upload(filename, data)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", filename, err)
+	}
+	attachmentURL = strings.TrimSpace(attachmentURL)
+	err = repo.AddAttachment(cid, attachmentURL)
+	if err != nil {
+		return err
+	}
+	uploaded[path.Base(attachmentURL)] = struct{}{}
+	uploaded[filename] = struct{}{}
+	log.Printf("UPLOAD %s as %s\n", filename, attachmentURL)
 	return nil
 }
 
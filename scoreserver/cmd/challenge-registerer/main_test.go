@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/repository"
+)
+
+// writeTree creates the files in dir in the given order, so that the order on the disk differs between trees
+func writeTree(t *testing.T, dir string, names []string, files map[string]string) {
+	t.Helper()
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(files[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompressDeterministic(t *testing.T) {
+	files := map[string]string{
+		"chall":         "binary",
+		"lib/libc.so.6": "libc",
+		"src/main.c":    "int main() {}",
+	}
+
+	dir1, err := ioutil.TempDir("", "distfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir1)
+	writeTree(t, dir1, []string{"chall", "lib/libc.so.6", "src/main.c"}, files)
+
+	dir2, err := ioutil.TempDir("", "distfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir2)
+	writeTree(t, dir2, []string{"src/main.c", "lib/libc.so.6", "chall"}, files)
+	// another checkout has other timestamps
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir2, "chall"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := compress(dir1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := compress(dir1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, again) {
+		t.Error("compressing the same tree twice should give the same bytes")
+	}
+	other, err := compress(dir2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, other) {
+		t.Error("the same files should give the same bytes regardless of the timestamps and the creation order")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	names := make([]string, 0)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !header.ModTime.Equal(archiveModTime) || header.Uid != 0 || header.Uname != "" {
+			t.Errorf("%s is not normalized: %+v", header.Name, header)
+		}
+		names = append(names, header.Name)
+	}
+	expected := []string{"chall", "lib/", "lib/libc.so.6", "src/", "src/main.c"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, but got %v", expected, names)
+	}
+}
+
+// testUploader records the uploaded filenames
+type testUploader struct {
+	uploaded []string
+}
+
+func (u *testUploader) Upload(name string, data []byte) (string, error) {
+	u.uploaded = append(u.uploaded, name)
+	return "https://files.example.com/" + name + "\n", nil
+}
+
+// attachmentRepository records the attachments. the other methods are not used by uploadAttachment
+type attachmentRepository struct {
+	repository.Repository
+	attachments []string
+}
+
+func (r *attachmentRepository) AddAttachment(cid uint32, url string) error {
+	r.attachments = append(r.attachments, url)
+	return nil
+}
+
+func TestUploadAttachmentSkip(t *testing.T) {
+	uploader := &testUploader{}
+	repo := &attachmentRepository{}
+	uploaded := map[string]struct{}{
+		"old_0123.tar.gz": struct{}{},
+	}
+
+	if err := uploadAttachment(1, "old_0123.tar.gz", []byte("old"), uploaded, repo, uploader); err != nil {
+		t.Fatal(err)
+	}
+	if len(uploader.uploaded) != 0 || len(repo.attachments) != 0 {
+		t.Errorf("an uploaded filename should be skipped, uploaded: %v, attachments: %v", uploader.uploaded, repo.attachments)
+	}
+
+	if err := uploadAttachment(1, "new_4567.tar.gz", []byte("new"), uploaded, repo, uploader); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploadAttachment(1, "new_4567.tar.gz", []byte("new"), uploaded, repo, uploader); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(uploader.uploaded, []string{"new_4567.tar.gz"}) {
+		t.Errorf("a new filename should be uploaded once, but %v", uploader.uploaded)
+	}
+	if !reflect.DeepEqual(repo.attachments, []string{"https://files.example.com/new_4567.tar.gz"}) {
+		t.Errorf("wrong attachments: %v", repo.attachments)
+	}
+}
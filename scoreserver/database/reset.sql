@@ -1,9 +1,16 @@
+DROP TABLE admin_audit_logs;
 DROP TABLE config;
 DROP TABLE submissions;
 DROP TABLE challenge_attachments;
 DROP TABLE challenge_tags;
 DROP TABLE challenges;
 DROP TABLE password_reset_tokens;
+DROP TABLE email_change_tokens;
+DROP TABLE email_verification_tokens;
 DROP TABLE tokens;
+DROP TABLE totp_recovery_codes;
+DROP TABLE api_tokens;
+DROP TABLE team_membership_histories;
+DROP TABLE user_identities;
 DROP TABLE users;
 DROP TABLE teams;
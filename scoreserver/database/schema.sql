@@ -1,8 +1,11 @@
 CREATE TABLE IF NOT EXISTS teams (
     id INT UNSIGNED NOT NULL,
     teamname VARCHAR(64) NOT NULL,
+    skeleton VARCHAR(255), -- to find confusable teamnames. the old teams are filled at startup, except the ones confusable with an older team
     token VARCHAR(64) NOT NULL,
     country_code CHAR(3) NOT NULL,
+    captain_id INT UNSIGNED,
+    icon_path TEXT,
     is_hidden BOOLEAN NOT NULL DEFAULT FALSE,
 
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -10,6 +13,7 @@ CREATE TABLE IF NOT EXISTS teams (
 
     PRIMARY KEY(`id`),
     UNIQUE KEY(`teamname`),
+    UNIQUE KEY(`skeleton`),
     UNIQUE KEY(`token`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
@@ -23,6 +27,11 @@ CREATE TABLE IF NOT EXISTS users (
     team_id INT UNSIGNED NOT NULL,
     is_hidden BOOLEAN NOT NULL DEFAULT FALSE,
     is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret VARCHAR(64), -- set on enrollment, used after totp_enabled
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT UNSIGNED NOT NULL DEFAULT 0, -- to reject replayed codes
+    ban_reason VARCHAR(255), -- banned when not null
 
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
@@ -33,22 +42,115 @@ CREATE TABLE IF NOT EXISTS users (
     FOREIGN KEY(`team_id`) REFERENCES `teams`(`id`) ON DELETE RESTRICT ON UPDATE RESTRICT
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(128) NOT NULL, -- the email when linked
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE `issuer_subject` (`issuer`, `subject`),
+    FOREIGN KEY(`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS team_membership_histories (
+    id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    team_id INT UNSIGNED NOT NULL,
+    action VARCHAR(16) NOT NULL, -- create, join, leave, kick
+    actor_id INT UNSIGNED,
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    FOREIGN KEY(`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY(`team_id`) REFERENCES `teams`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 CREATE TABLE IF NOT EXISTS tokens (
+    id INT UNSIGNED NOT NULL, -- to refer a session without the token
     user_id INT UNSIGNED NOT NULL,
     token VARCHAR(64) NOT NULL,
     expires_at INT UNSIGNED NOT NULL,
     revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    mfa BOOLEAN NOT NULL DEFAULT FALSE, -- logged in with the second factor
+    ip VARCHAR(64) NOT NULL DEFAULT '', -- when logged in
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    last_seen_at INT UNSIGNED,
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`token_hash`),
+    UNIQUE KEY (`id`),
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    token_hash CHAR(64) NOT NULL, -- sha256 of the token. the token itself is shown only once
+    scopes VARCHAR(255) NOT NULL, -- comma separated
+    expires_at INT UNSIGNED, -- null means no expiry
+    last_used_at INT UNSIGNED,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
 
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
-    PRIMARY KEY (`token`),
+    PRIMARY KEY (`id`),
+    UNIQUE KEY (`token_hash`),
     FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+    user_id INT UNSIGNED NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`user_id`, `code_hash`),
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 CREATE TABLE IF NOT EXISTS password_reset_tokens (
     user_id INT UNSIGNED NOT NULL,
+    token_hash CHAR(64) NOT NULL, -- sha256 of the token. the token itself is only in the mail
+    expires_at INT UNSIGNED NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`token_hash`),
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    user_id INT UNSIGNED NOT NULL,
+    token_hash CHAR(64) NOT NULL, -- sha256 of the token. the token itself is only in the mail
+    expires_at INT UNSIGNED NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`token_hash`),
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS email_change_tokens (
+    user_id INT UNSIGNED NOT NULL,
+    email VARCHAR(128) NOT NULL,
     token VARCHAR(64) NOT NULL,
     expires_at INT UNSIGNED NOT NULL,
     revoked BOOLEAN NOT NULL DEFAULT FALSE,
@@ -56,7 +158,7 @@ CREATE TABLE IF NOT EXISTS password_reset_tokens (
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
-    PRIMARY KEY (`token`),
+    PRIMARY KEY (`token_hash`),
     FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
@@ -120,6 +222,7 @@ CREATE TABLE IF NOT EXISTS submissions (
     submitted_at INT UNSIGNED NOT NULL,
     is_correct BOOLEAN NOT NULL,
     is_valid BOOLEAN NOT NULL,
+    granted_by INT UNSIGNED, -- the admin who granted the solve. null for the submitted flags
 
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
@@ -140,5 +243,37 @@ CREATE TABLE IF NOT EXISTS config (
 
     lock_second INT NOT NULL,
     lock_duration INT NOT NULL,
-    lock_count INT NOT NULL
+    lock_count INT NOT NULL,
+
+    max_team_size INT NOT NULL DEFAULT 0, -- 0 means unlimited
+    email_verification BOOLEAN NOT NULL DEFAULT FALSE,
+    require_admin_totp BOOLEAN NOT NULL DEFAULT FALSE,
+    solo_mode BOOLEAN NOT NULL DEFAULT FALSE, -- every user is their own team
+
+    registration_policy VARCHAR(16) NOT NULL DEFAULT 'open', -- open, closed, invite, domain
+    registration_invite_code VARCHAR(64) NOT NULL DEFAULT '',
+    registration_domains VARCHAR(1024) NOT NULL DEFAULT '', -- comma separated
+    registration_start DATETIME, -- no limit when null
+    registration_end DATETIME
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- admin_audit_logs is append-only: the scoreserver only inserts and selects the rows.
+-- grant only INSERT and SELECT on this table to the user of the scoreserver to enforce it
+CREATE TABLE IF NOT EXISTS admin_audit_logs (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT, -- the order of the actions
+    actor_id INT UNSIGNED NOT NULL, -- no foreign key so that the logs outlive the admin
+    actor_name VARCHAR(64) NOT NULL,
+    method VARCHAR(8) NOT NULL,
+    path VARCHAR(255) NOT NULL, -- the route, e.g. /admin/users/:id/export
+    ip VARCHAR(64) NOT NULL,
+    status INT NOT NULL,
+    target VARCHAR(64) NOT NULL, -- e.g. config, challenges, user:<id>, team:<id>
+    request TEXT NOT NULL, -- the request body without the secrets
+    diff MEDIUMTEXT NOT NULL, -- {"field": {"before": ..., "after": ...}}
+    acted_at INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY(`id`),
+    INDEX(`actor_id`),
+    INDEX(`target`),
+    INDEX(`acted_at`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -4,6 +4,7 @@ go 1.13
 
 require (
 	github.com/aws/aws-sdk-go v1.29.18
+	github.com/coreos/go-oidc v2.2.1+incompatible
 	github.com/go-redis/redis/v7 v7.2.0
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/google/uuid v1.1.1
@@ -11,10 +12,14 @@ require (
 	github.com/jmoiron/sqlx v1.2.1-0.20191203222853-2ba0fc60eb4a
 	github.com/labstack/echo/v4 v4.1.14
 	github.com/pariz/gountries v0.0.0-20191029140926-233bc78cf5b5
+	github.com/pquerna/cachecontrol v0.2.0 // indirect
 	github.com/rakyll/statik v0.1.6
 	golang.org/x/crypto v0.0.0-20191227163750-53104e6ec876
-	golang.org/x/exp v0.0.0-20200228211341-fcea875c7e85
+	golang.org/x/image v0.0.0-20200119044424-58c23975cae1
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+	golang.org/x/text v0.3.2
+	gopkg.in/square/go-jose.v2 v2.6.0
 	gopkg.in/src-d/go-billy.v4 v4.3.2
 	gopkg.in/src-d/go-git.v4 v4.13.1
-	gopkg.in/yaml.v2 v2.2.4
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/repository"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/server"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/service"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/storage"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/webhook"
 )
 
@@ -50,6 +52,20 @@ func run() error {
 		return fmt.Errorf("Environmental variable 'FRONT' is required")
 	}
 
+	secret := os.Getenv("SECRET")
+	if secret == "" {
+		return fmt.Errorf("Environmental variable 'SECRET' is required")
+	}
+
+	iconDir := os.Getenv("ICON_DIR")
+	if iconDir == "" {
+		iconDir = "icons"
+	}
+	storage, err := storage.NewLocal(iconDir)
+	if err != nil {
+		return err
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8000"
@@ -59,8 +75,24 @@ func run() error {
 	if err != nil {
 		return err
 	}
-	app := service.New(repo, redis, mailer, webhook)
-	srv := server.New(app, []string{frontOrigin})
+	app := service.New(repo, redis, mailer, webhook, storage, frontOrigin)
+	if err := app.Backfill(); err != nil {
+		return err
+	}
+	var oidc *server.OIDC
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		oidc, err = server.NewOIDC(context.Background(), server.OIDCConfig{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			FrontOrigin:  frontOrigin,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	srv := server.New(app, []string{frontOrigin}, []byte(secret), oidc)
 	go app.HandleMessage()
 	return srv.Start(":" + port)
 }
@@ -33,3 +33,12 @@ func UserChallenge(chal *Challenge) (*UserChallengeInfo, error) {
 		SolveTeams:    chal.SolveTeams,
 	}, nil
 }
+
+func PublicStatistics(stats *ChallengeStatistics) *PublicChallengeStatistics {
+	return &PublicChallengeStatistics{
+		ChallengeID: stats.ChallengeID,
+		Name:        stats.Name,
+		Solves:      stats.Solves,
+		FirstBlood:  stats.FirstBlood,
+	}
+}
@@ -13,6 +13,21 @@ func IsNotFound(err error) bool {
 	return ok
 }
 
+type teamFullError string
+
+func (err teamFullError) Error() string {
+	return string(err) + " is full"
+}
+
+func TeamFullError(typ string) error {
+	return teamFullError(typ)
+}
+
+func IsTeamFull(err error) bool {
+	_, ok := err.(teamFullError)
+	return ok
+}
+
 type duplicateError string
 
 func (err duplicateError) Error() string {
@@ -1,44 +1,117 @@
 package model
 
+// Token is a login session
 type Token struct {
+	ID         uint32 `db:"id" json:"id"`
+	UserID     uint32 `db:"user_id" json:"-"`
+	Token      string `db:"token" json:"-"`
+	ExpiresAt  int64  `db:"expires_at" json:"expires_at"`
+	MFA        bool   `db:"mfa" json:"mfa"`
+	IP         string `db:"ip" json:"ip"`
+	UserAgent  string `db:"user_agent" json:"user_agent"`
+	LastSeenAt *int64 `db:"last_seen_at" json:"last_seen_at"`
+	Current    bool   `db:"-" json:"current"`
+
+	CreatedAt string `db:"created_at" json:"created_at"`
+	UpdatedAt string `db:"updated_at" json:"-"`
+}
+
+// SessionInfo is where a session is created from
+type SessionInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// scopes of personal API tokens
+const (
+	ScopeReadChallenges = "read:challenges"
+	ScopeSubmit         = "submit"
+	ScopeAdmin          = "admin"
+)
+
+type APIToken struct {
+	ID         uint32   `db:"id" json:"id"`
+	UserID     uint32   `db:"user_id" json:"-"`
+	Name       string   `db:"name" json:"name"`
+	TokenHash  string   `db:"token_hash" json:"-"`
+	Scopes     string   `db:"scopes" json:"-"`
+	ScopeList  []string `db:"-" json:"scopes"`
+	ExpiresAt  *int64   `db:"expires_at" json:"expires_at"`
+	LastUsedAt *int64   `db:"last_used_at" json:"last_used_at"`
+	Revoked    bool     `db:"revoked" json:"-"`
+
+	CreatedAt string `db:"created_at" json:"created_at"`
+	UpdatedAt string `db:"updated_at" json:"-"`
+}
+
+type PasswordResetToken struct {
 	UserID    uint32 `db:"user_id"`
-	Token     string `db:"token"`
+	TokenHash string `db:"token_hash"`
 	ExpiresAt int64  `db:"expires_at"`
 
 	CreatedAt string `db:"created_at" json:"-"`
 	UpdatedAt string `db:"updated_at" json:"-"`
 }
 
-type PasswordResetToken struct {
+type EmailVerificationToken struct {
+	UserID    uint32 `db:"user_id"`
+	TokenHash string `db:"token_hash"`
+	ExpiresAt int64  `db:"expires_at"`
+	Revoked   bool   `db:"revoked"`
+
+	CreatedAt string `db:"created_at" json:"-"`
+	UpdatedAt string `db:"updated_at" json:"-"`
+}
+
+type EmailChangeToken struct {
 	UserID    uint32 `db:"user_id"`
+	Email     string `db:"email"`
 	Token     string `db:"token"`
 	ExpiresAt int64  `db:"expires_at"`
+	Revoked   bool   `db:"revoked"`
 
 	CreatedAt string `db:"created_at" json:"-"`
 	UpdatedAt string `db:"updated_at" json:"-"`
 }
 
+// Identity is an account of an external OpenID Connect provider.
+// it is linked to at most one user by (Issuer, Subject)
+type Identity struct {
+	Issuer        string `db:"issuer" json:"issuer"`
+	Subject       string `db:"subject" json:"subject"`
+	Email         string `db:"email" json:"email"`
+	EmailVerified bool   `db:"-" json:"email_verified"`
+}
+
 type User struct {
-	ID           uint32 `db:"id" json:"id"`
-	Username     string `db:"username" json:"username"`
-	Email        string `db:"email" json:"-"`
-	PasswordHash string `db:"password_hash" json:"-"`
-	Password     string
-	IconPath     *string `db:"icon_path" json:"icon_path"`
-	TeamID       uint32  `db:"team_id" json:"team_id"`
-	IsHidden     bool    `db:"is_hidden" json:"-"`
-	IsAdmin      bool    `db:"is_admin" json:"-"`
+	ID            uint32 `db:"id" json:"id"`
+	Username      string `db:"username" json:"username"`
+	Email         string `db:"email" json:"-"`
+	PasswordHash  string `db:"password_hash" json:"-"`
+	Password      string
+	IconPath      *string `db:"icon_path" json:"icon_path"`
+	TeamID        uint32  `db:"team_id" json:"team_id"`
+	IsHidden      bool    `db:"is_hidden" json:"-"`
+	IsAdmin       bool    `db:"is_admin" json:"-"`
+	EmailVerified bool    `db:"email_verified" json:"-"`
+	TOTPSecret    *string `db:"totp_secret" json:"-"`
+	TOTPEnabled   bool    `db:"totp_enabled" json:"-"`
+	TOTPLastStep  uint64  `db:"totp_last_step" json:"-"`
+	BanReason     *string `db:"ban_reason" json:"-"`
 
 	CreatedAt string `db:"created_at" json:"-"`
 	UpdatedAt string `db:"updated_at" json:"-"`
 }
 
 type Team struct {
-	ID          uint32 `db:"id" json:"id"`
-	Teamname    string `db:"teamname" json:"teamname"`
-	Token       string `db:"token" json:"token"`
-	CountryCode string `db:"country_code" json:"country_code"`
-	IsHidden    bool   `db:"is_hidden" json:"-"`
+	ID          uint32  `db:"id" json:"id"`
+	Teamname    string  `db:"teamname" json:"teamname"`
+	Skeleton    *string `db:"skeleton" json:"-"`
+	Token       string  `db:"token" json:"token"`
+	CountryCode string  `db:"country_code" json:"country_code"`
+	CaptainID   *uint32 `db:"captain_id" json:"captain_id"`
+	IconPath    *string `db:"icon_path" json:"icon_path"`
+	IsHidden    bool    `db:"is_hidden" json:"-"`
 
 	Submissions []*Submission `json:"submissions"`
 	Users       []*User       `json:"users"`
@@ -47,6 +120,174 @@ type Team struct {
 	UpdatedAt string `db:"updated_at" json:"-"`
 }
 
+// AdminUser is a user with the fields only admins can see
+type AdminUser struct {
+	ID            uint32  `db:"id" json:"id"`
+	Username      string  `db:"username" json:"username"`
+	Email         string  `db:"email" json:"email"`
+	TeamID        uint32  `db:"team_id" json:"team_id"`
+	Teamname      string  `db:"teamname" json:"teamname"`
+	IsHidden      bool    `db:"is_hidden" json:"is_hidden"`
+	IsAdmin       bool    `db:"is_admin" json:"is_admin"`
+	BanReason     *string `db:"ban_reason" json:"ban_reason"`
+	EmailVerified bool    `db:"email_verified" json:"email_verified"`
+	TOTPEnabled   bool    `db:"totp_enabled" json:"totp_enabled"`
+
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+// UserSubmission is a submission with the flag, exported to the user who sent it
+type UserSubmission struct {
+	ID            uint32  `db:"id" json:"id"`
+	ChallengeID   *uint32 `db:"challenge_id" json:"challenge_id"`
+	ChallengeName *string `db:"challenge_name" json:"challenge_name"`
+	TeamID        *uint32 `db:"team_id" json:"team_id"`
+	Flag          string  `db:"flag" json:"flag"`
+	SubmittedAt   int64   `db:"submitted_at" json:"submitted_at"`
+	IsCorrect     bool    `db:"is_correct" json:"is_correct"`
+	IsValid       bool    `db:"is_valid" json:"is_valid"`
+}
+
+// UserExport is every data about the user, exported on request
+type UserExport struct {
+	Profile     *AdminUser        `json:"profile"`
+	Team        *Team             `json:"team"`
+	Identities  []*Identity       `json:"identities"`
+	Sessions    []*Token          `json:"sessions"`
+	APITokens   []*APIToken       `json:"api_tokens"`
+	Submissions []*UserSubmission `json:"submissions"`
+	ExportedAt  int64             `json:"exported_at"`
+}
+
+// SubmissionLog is a submission with the names, for admins
+type SubmissionLog struct {
+	ID            uint32  `db:"id" json:"id"`
+	ChallengeID   *uint32 `db:"challenge_id" json:"challenge_id"`
+	ChallengeName *string `db:"challenge_name" json:"challenge_name"`
+	UserID        *uint32 `db:"user_id" json:"user_id"`
+	Username      *string `db:"username" json:"username"`
+	TeamID        *uint32 `db:"team_id" json:"team_id"`
+	Teamname      *string `db:"teamname" json:"teamname"`
+	Flag          string  `db:"flag" json:"flag"`
+	SubmittedAt   int64   `db:"submitted_at" json:"submitted_at"`
+	IsCorrect     bool    `db:"is_correct" json:"is_correct"`
+	IsValid       bool    `db:"is_valid" json:"is_valid"`
+	GrantedBy     *uint32 `db:"granted_by" json:"granted_by"`
+}
+
+// SubmissionFilter is the condition to search submissions. nil fields match everything
+type SubmissionFilter struct {
+	TeamID      *uint32
+	UserID      *uint32
+	ChallengeID *uint32
+	IsCorrect   *bool
+	IsValid     *bool
+	Since       *int64 // inclusive
+	Until       *int64 // exclusive
+}
+
+// SubmissionCursor is the last submission of the previous page. submissions are ordered by the time and the id
+type SubmissionCursor struct {
+	SubmittedAt int64
+	ID          uint32
+}
+
+// AuditLog is an action of an admin. the logs are never updated nor deleted
+type AuditLog struct {
+	ID        uint64 `db:"id" json:"id"`
+	ActorID   uint32 `db:"actor_id" json:"actor_id"`
+	ActorName string `db:"actor_name" json:"actor_name"`
+	Method    string `db:"method" json:"method"`
+	Path      string `db:"path" json:"path"`
+	IP        string `db:"ip" json:"ip"`
+	Status    int    `db:"status" json:"status"`
+	Target    string `db:"target" json:"target"`
+	Request   string `db:"request" json:"request"` // JSON
+	Diff      string `db:"diff" json:"diff"`       // JSON. empty when the action has no target object
+	ActedAt   int64  `db:"acted_at" json:"acted_at"`
+}
+
+// AuditFilter is the condition to search audit logs. nil and empty fields match everything
+type AuditFilter struct {
+	ActorID *uint32
+	Path    string
+	Target  string
+	Since   *int64 // inclusive
+	Until   *int64 // exclusive
+}
+
+// Dashboard is the live status of the CTF for admins
+type Dashboard struct {
+	Registrations []*RegistrationCount `json:"registrations"`
+	Clients       int                  `json:"clients"`
+	Submissions   []*SubmissionCount   `json:"submissions"`
+	LockedTeams   []*LockedTeam        `json:"locked_teams"`
+	RecentSolves  []*RecentSolve       `json:"recent_solves"`
+}
+
+// RegistrationCount is the number of users registered in the hour
+type RegistrationCount struct {
+	Time  int64 `db:"time" json:"time"`
+	Count int   `db:"count" json:"count"`
+}
+
+// SubmissionCount is the number of submissions in the minute. duplicate is a correct but not valid submission
+type SubmissionCount struct {
+	Time      int64 `db:"time" json:"time"`
+	Correct   int   `db:"correct" json:"correct"`
+	Wrong     int   `db:"wrong" json:"wrong"`
+	Duplicate int   `db:"duplicate" json:"duplicate"`
+}
+
+// LockedTeam is a team whose submission is locked for too many wrong flags
+type LockedTeam struct {
+	TeamID    uint32 `json:"team_id"`
+	Teamname  string `json:"teamname"`
+	ExpiresIn int64  `json:"expires_in"` // seconds
+}
+
+type RecentSolve struct {
+	SubmissionID  uint32  `db:"id" json:"id"`
+	TeamID        uint32  `db:"team_id" json:"team_id"`
+	Teamname      string  `db:"teamname" json:"teamname"`
+	UserID        *uint32 `db:"user_id" json:"user_id"`
+	Username      *string `db:"username" json:"username"`
+	ChallengeID   *uint32 `db:"challenge_id" json:"challenge_id"`
+	ChallengeName *string `db:"challenge_name" json:"challenge_name"`
+	SubmittedAt   int64   `db:"submitted_at" json:"submitted_at"`
+}
+
+// AdminTeam is a team with the fields only admins can see
+type AdminTeam struct {
+	ID          uint32  `db:"id" json:"id"`
+	Teamname    string  `db:"teamname" json:"teamname"`
+	CountryCode string  `db:"country_code" json:"country_code"`
+	CaptainID   *uint32 `db:"captain_id" json:"captain_id"`
+	IsHidden    bool    `db:"is_hidden" json:"is_hidden"`
+	Members     int     `db:"members" json:"members"`
+	Solves      int     `db:"solves" json:"solves"`
+
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+const (
+	MembershipCreate = "create"
+	MembershipJoin   = "join"
+	MembershipLeave  = "leave"
+	MembershipKick   = "kick"
+)
+
+type TeamMembershipHistory struct {
+	ID      uint32  `db:"id" json:"id"`
+	UserID  uint32  `db:"user_id" json:"user_id"`
+	TeamID  uint32  `db:"team_id" json:"team_id"`
+	Action  string  `db:"action" json:"action"`
+	ActorID *uint32 `db:"actor_id" json:"actor_id"`
+
+	CreatedAt string `db:"created_at" json:"created_at"`
+	UpdatedAt string `db:"updated_at" json:"-"`
+}
+
 type Config struct {
 	CTFName string `db:"ctf_name" json:"ctf_name"`
 	StartAt int64  `db:"start_at" json:"start_at"`
@@ -60,10 +301,29 @@ type Config struct {
 	EasySolves   int `db:"easy_solves" json:"easy_solves"`
 	MediumSolves int `db:"medium_solves" json:"medium_solves"`
 
+	MaxTeamSize       int  `db:"max_team_size" json:"max_team_size"`
+	EmailVerification bool `db:"email_verification" json:"email_verification"`
+	RequireAdminTOTP  bool `db:"require_admin_totp" json:"require_admin_totp"`
+	SoloMode          bool `db:"solo_mode" json:"solo_mode"`
+
+	RegistrationPolicy     string `db:"registration_policy" json:"registration_policy"`
+	RegistrationInviteCode string `db:"registration_invite_code" json:"-"`
+	RegistrationDomains    string `db:"registration_domains" json:"registration_domains"`
+	RegistrationStart      *int64 `db:"registration_start" json:"registration_start"`
+	RegistrationEnd        *int64 `db:"registration_end" json:"registration_end"`
+
 	CreatedAt string `db:"created_at" json:"-"`
 	UpdatedAt string `db:"updated_at" json:"-"`
 }
 
+// registration policies
+const (
+	RegistrationOpen   = "open"
+	RegistrationClosed = "closed"
+	RegistrationInvite = "invite" // needs the invite code
+	RegistrationDomain = "domain" // only for the email domains in RegistrationDomains
+)
+
 type Challenge struct {
 	ID            uint32   `db:"id" json:"id"`
 	Name          string   `db:"name" json:"name"`
@@ -127,9 +387,41 @@ type Submission struct {
 	Flag        string  `db:"flag" json:"-"`
 	SubmittedAt int64   `db:"submitted_at" json:"submitted_at"`
 
-	IsCorrect bool `db:"is_correct" json:"-"`
-	IsValid   bool `db:"is_valid" json:"-"`
+	IsCorrect bool    `db:"is_correct" json:"-"`
+	IsValid   bool    `db:"is_valid" json:"-"`
+	GrantedBy *uint32 `db:"granted_by" json:"-"`
 
 	CreatedAt string `db:"created_at" json:"-"`
 	UpdatedAt string `db:"updated_at" json:"-"`
 }
+
+type ChallengeStatistics struct {
+	ChallengeID      uint32         `json:"challenge_id"`
+	Name             string         `json:"name"`
+	Solves           int            `json:"solves"`
+	RepeatSolves     int            `json:"repeat_solves"`
+	FirstBlood       *FirstBlood    `json:"first_blood"`
+	TimeToFirstSolve *int64         `json:"time_to_first_solve"`
+	SolvesOverTime   []SolveCount   `json:"solves_over_time"`
+	SolvesByCountry  map[string]int `json:"solves_by_country"`
+	Score            int            `json:"score"`
+	BaseScore        int            `json:"base_score"`
+}
+
+type FirstBlood struct {
+	TeamID   uint32 `json:"team_id"`
+	Teamname string `json:"teamname"`
+	SolvedAt int64  `json:"solved_at"`
+}
+
+type SolveCount struct {
+	Time   int64 `json:"time"`
+	Solves int   `json:"solves"`
+}
+
+type PublicChallengeStatistics struct {
+	ChallengeID uint32      `json:"challenge_id"`
+	Name        string      `json:"name"`
+	Solves      int         `json:"solves"`
+	FirstBlood  *FirstBlood `json:"first_blood"`
+}
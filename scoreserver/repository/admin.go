@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type AdminRepository interface {
+	ListAdminUsers(query string) ([]*model.AdminUser, error)
+	ListAdminTeams(query string) ([]*model.AdminTeam, error)
+	FindAdminTeam(tid uint32) (*model.AdminTeam, error)
+
+	SetUserHidden(uid uint32, hidden bool) error
+	SetUserAdmin(uid uint32, admin bool) error
+	SetBanReason(uid uint32, reason *string) error
+	SetTeamHidden(tid uint32, hidden bool) error
+	DeleteTeam(tid uint32) error
+}
+
+// ListAdminUsers returns the users whose username, email or teamname contains the query. empty query matches everyone
+func (r *repository) ListAdminUsers(query string) ([]*model.AdminUser, error) {
+	users := make([]*model.AdminUser, 0)
+	pattern := likePattern(query)
+	err := r.db.Select(
+		&users,
+		`SELECT users.id, username, email, team_id, teamname, users.is_hidden, is_admin, ban_reason, email_verified, totp_enabled, users.created_at
+		FROM users
+		INNER JOIN teams
+		ON users.team_id = teams.id
+		WHERE ? = '' OR username LIKE ? OR email LIKE ? OR teamname LIKE ?
+		ORDER BY users.created_at ASC`,
+		query, pattern, pattern, pattern,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return users, nil
+}
+
+// ListAdminTeams returns the teams whose teamname contains the query with the number of the members and valid solves
+func (r *repository) ListAdminTeams(query string) ([]*model.AdminTeam, error) {
+	teams := make([]*model.AdminTeam, 0)
+	err := r.db.Select(
+		&teams,
+		`SELECT id, teamname, country_code, captain_id, is_hidden, created_at,
+			(SELECT COUNT(*) FROM users WHERE users.team_id = teams.id) AS members,
+			(SELECT COUNT(*) FROM submissions WHERE submissions.team_id = teams.id AND is_valid = TRUE) AS solves
+		FROM teams
+		WHERE ? = '' OR teamname LIKE ?
+		ORDER BY created_at ASC`,
+		query, likePattern(query),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return teams, nil
+}
+
+func (r *repository) FindAdminTeam(tid uint32) (*model.AdminTeam, error) {
+	var team model.AdminTeam
+	err := r.db.Get(
+		&team,
+		`SELECT id, teamname, country_code, captain_id, is_hidden, created_at,
+			(SELECT COUNT(*) FROM users WHERE users.team_id = teams.id) AS members,
+			(SELECT COUNT(*) FROM submissions WHERE submissions.team_id = teams.id AND is_valid = TRUE) AS solves
+		FROM teams
+		WHERE id = ?`,
+		tid,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("team")
+		}
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &team, nil
+}
+
+func (r *repository) SetUserHidden(uid uint32, hidden bool) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET is_hidden = ?
+		WHERE id = ?`,
+		hidden, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetUserAdmin(uid uint32, admin bool) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET is_admin = ?
+		WHERE id = ?`,
+		admin, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// SetBanReason bans the user when reason is not nil, otherwise unbans
+func (r *repository) SetBanReason(uid uint32, reason *string) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET ban_reason = ?
+		WHERE id = ?`,
+		reason, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetTeamHidden(tid uint32, hidden bool) error {
+	_, err := r.db.Exec(
+		`UPDATE teams
+		SET is_hidden = ?
+		WHERE id = ?`,
+		hidden, tid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// DeleteTeam deletes the team with its members and submissions
+func (r *repository) DeleteTeam(tid uint32) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM submissions WHERE team_id = ?`, tid); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE team_id = ?`, tid); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM teams WHERE id = ?`, tid)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("%w", err)
+	} else if n == 0 {
+		return model.NotFoundError("team")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if err := r.redis.Del(solvedChallengesKey(tid)).Err(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// likePattern makes a LIKE pattern which matches strings containing s
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
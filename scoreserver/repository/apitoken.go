@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type APITokenRepository interface {
+	NewAPIToken(uid uint32, name, tokenHash string, scopes []string, expiresAt *int64) (uint32, error)
+	FindAPITokenByHash(tokenHash string) (*model.APIToken, error)
+	ListAPITokens(uid uint32) ([]*model.APIToken, error)
+	RevokeAPIToken(uid, id uint32) error
+	TouchAPIToken(id uint32) error
+}
+
+func (r *repository) NewAPIToken(uid uint32, name, tokenHash string, scopes []string, expiresAt *int64) (uint32, error) {
+	id := r.newID()
+	_, err := r.db.Exec(
+		`INSERT INTO api_tokens(id, user_id, name, token_hash, scopes, expires_at, revoked)
+		VALUES (?, ?, ?, ?, ?, ?, FALSE)`,
+		id, uid, name, tokenHash, strings.Join(scopes, ","), expiresAt,
+	)
+	if err != nil {
+		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+			return 0, model.DuplicateError("api token")
+		}
+		return 0, fmt.Errorf("%w", err)
+	}
+	return id, nil
+}
+
+// FindAPITokenByHash returns only tokens which are neither revoked nor expired
+func (r *repository) FindAPITokenByHash(tokenHash string) (*model.APIToken, error) {
+	var t model.APIToken
+	now := time.Now().Unix()
+
+	err := r.db.Get(
+		&t,
+		`SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked, created_at, updated_at
+		FROM api_tokens
+		WHERE token_hash = ? AND revoked = FALSE AND (expires_at IS NULL OR expires_at > ?)
+		LIMIT 1`,
+		tokenHash, now,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("api token")
+		}
+		return nil, fmt.Errorf("%w", err)
+	}
+	t.ScopeList = splitScopes(t.Scopes)
+	return &t, nil
+}
+
+func (r *repository) ListAPITokens(uid uint32) ([]*model.APIToken, error) {
+	tokens := make([]*model.APIToken, 0)
+	err := r.db.Select(
+		&tokens,
+		`SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked, created_at, updated_at
+		FROM api_tokens
+		WHERE user_id = ? AND revoked = FALSE
+		ORDER BY created_at`,
+		uid,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	for _, t := range tokens {
+		t.ScopeList = splitScopes(t.Scopes)
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken revokes the token only when it belongs to the user
+func (r *repository) RevokeAPIToken(uid, id uint32) error {
+	res, err := r.db.Exec(
+		`UPDATE api_tokens
+		SET revoked = TRUE
+		WHERE id = ? AND user_id = ? AND revoked = FALSE`,
+		id, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if n == 0 {
+		return model.NotFoundError("api token")
+	}
+	return nil
+}
+
+func (r *repository) TouchAPIToken(id uint32) error {
+	_, err := r.db.Exec(
+		`UPDATE api_tokens
+		SET last_used_at = ?
+		WHERE id = ?`,
+		time.Now().Unix(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+	return strings.Split(scopes, ",")
+}
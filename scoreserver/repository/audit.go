@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+// AuditLogRepository only appends and reads the logs. do not add the functions to update or delete them
+type AuditLogRepository interface {
+	InsertAuditLog(log *model.AuditLog) error
+	ListAuditLogs(filter *model.AuditFilter, cursor *uint64, limit int) ([]*model.AuditLog, error)
+}
+
+func (r *repository) InsertAuditLog(log *model.AuditLog) error {
+	_, err := r.db.Exec(
+		`INSERT INTO
+		admin_audit_logs(actor_id, actor_name, method, path, ip, status, target, request, diff, acted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		log.ActorID, log.ActorName, log.Method, log.Path, log.IP, log.Status, log.Target, log.Request, log.Diff, log.ActedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ListAuditLogs returns the logs matching the filter, the newest first.
+// when cursor is not nil, the logs older than the cursor are returned
+func (r *repository) ListAuditLogs(filter *model.AuditFilter, cursor *uint64, limit int) ([]*model.AuditLog, error) {
+	conds := []string{"TRUE"}
+	args := make([]interface{}, 0)
+	if filter.ActorID != nil {
+		conds = append(conds, "actor_id = ?")
+		args = append(args, *filter.ActorID)
+	}
+	if filter.Path != "" {
+		conds = append(conds, "path = ?")
+		args = append(args, filter.Path)
+	}
+	if filter.Target != "" {
+		conds = append(conds, "target = ?")
+		args = append(args, filter.Target)
+	}
+	if filter.Since != nil {
+		conds = append(conds, "acted_at >= ?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		conds = append(conds, "acted_at < ?")
+		args = append(args, *filter.Until)
+	}
+	if cursor != nil {
+		conds = append(conds, "id < ?")
+		args = append(args, *cursor)
+	}
+	args = append(args, limit)
+
+	logs := make([]*model.AuditLog, 0)
+	err := r.db.Select(
+		&logs,
+		fmt.Sprintf(`SELECT *
+		FROM admin_audit_logs
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT ?`, strings.Join(conds, " AND ")),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return logs, nil
+}
@@ -21,6 +21,7 @@ type ChallengeRepository interface {
 	RegisterChallenge(name, flag, desc, category, difficulty, author string, tags []string, baseScore int, isDynamic, isQuestionary bool, host, port *string) (uint32, error)
 	UpdateChallengeByName(name, flag, desc, category, difficulty, author string, baseScore int, isDynamic, isQuestionary bool, host, port *string) error
 	AddAttachment(cid uint32, url string) error
+	ListAttachments(cid uint32) ([]*model.Attachment, error)
 
 	OpenChallenge(id uint32) error
 	CloseChallenge(id uint32) error
@@ -31,6 +32,7 @@ type ChallengeRepository interface {
 	ListAllChallenges(opened bool) ([]*model.Challenge, error)
 
 	AddSolvedChallenge(tid, cid uint32) error
+	RemoveSolvedChallenge(tid, cid uint32) error
 	TeamSolvedChallenges(tid uint32) ([]uint32, error)
 	UpdateScore(cid uint32, score int) error
 }
@@ -109,6 +111,22 @@ func (r *repository) AddAttachment(cid uint32, url string) error {
 	return nil
 }
 
+func (r *repository) ListAttachments(cid uint32) ([]*model.Attachment, error) {
+	attachments := make([]*model.Attachment, 0)
+	err := r.db.Select(
+		&attachments,
+		`SELECT *
+		FROM challenge_attachments
+		WHERE challenge_id = ?
+		ORDER BY created_at ASC`,
+		cid,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return attachments, nil
+}
+
 func (r *repository) OpenChallenge(id uint32) error {
 	_, err := r.db.Exec(
 		`UPDATE challenges
@@ -246,6 +264,15 @@ func (r *repository) AddSolvedChallenge(tid, cid uint32) error {
 	return nil
 }
 
+func (r *repository) RemoveSolvedChallenge(tid, cid uint32) error {
+	key := solvedChallengesKey(tid)
+	err := r.redis.SRem(key, cid).Err()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
 func (r *repository) TeamSolvedChallenges(tid uint32) ([]uint32, error) {
 	key := solvedChallengesKey(tid)
 	chals, err := r.redis.SMembers(key).Result()
@@ -364,6 +391,8 @@ func (r *repository) setChallengesolveTeam(chal model.Challenge) (model.Challeng
 		&submissions,
 		`SELECT team_id
 		FROM submissions
+		INNER JOIN teams
+		ON submissions.team_id = teams.id AND NOT teams.is_hidden
 		WHERE is_valid = TRUE AND challenge_id = ?
 		`,
 		chal.ID,
@@ -389,6 +418,8 @@ func (r *repository) setChallengeSolveTeams(chals []*model.Challenge) ([]*model.
 		&submissions,
 		`SELECT team_id, challenge_id
 		FROM submissions
+		INNER JOIN teams
+		ON submissions.team_id = teams.id AND NOT teams.is_hidden
 		WHERE is_valid = TRUE
 		`,
 	)
@@ -413,6 +444,11 @@ func (r *repository) setChallengeSolveTeams(chals []*model.Challenge) ([]*model.
 }
 
 func (r *repository) setChallengeScore(chal model.Challenge) (model.Challenge, error) {
+	// tools like challenge-registerer work without redis
+	if r.redis == nil {
+		chal.Score = chal.BaseScore
+		return chal, nil
+	}
 	key := challengeScoreKey(chal.ID)
 	scoreStr, err := r.redis.HGet(challengeScoreHashKey, key).Result()
 	if err == redis.Nil {
@@ -431,6 +467,12 @@ func (r *repository) setChallengeScore(chal model.Challenge) (model.Challenge, e
 }
 
 func (r *repository) setChallengeScores(chals []*model.Challenge) ([]*model.Challenge, error) {
+	if r.redis == nil {
+		for i := 0; i < len(chals); i++ {
+			chals[i].Score = chals[i].BaseScore
+		}
+		return chals, nil
+	}
 	scores, err := r.redis.HGetAll(challengeScoreHashKey).Result()
 	if err == redis.Nil {
 		for i := 0; i < len(chals); i++ {
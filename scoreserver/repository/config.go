@@ -14,6 +14,11 @@ type ConfigRepository interface {
 	SetLock(second, count, duration int) error
 	SetSolves(easy, medium int) error
 	SetMinScore(score int) error
+	SetMaxTeamSize(size int) error
+	SetEmailVerification(enabled bool) error
+	SetRequireAdminTOTP(required bool) error
+	SetSoloMode(enabled bool) error
+	SetRegistration(policy, inviteCode, domains string, start, end *int64) error
 	GetConfig() (*model.Config, error)
 }
 
@@ -76,11 +81,72 @@ func (r *repository) SetMinScore(score int) error {
 	return nil
 }
 
+func (r *repository) SetMaxTeamSize(size int) error {
+	_, err := r.db.Exec(
+		`UPDATE config
+		SET max_team_size = ?`,
+		size,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetEmailVerification(enabled bool) error {
+	_, err := r.db.Exec(
+		`UPDATE config
+		SET email_verification = ?`,
+		enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetRequireAdminTOTP(required bool) error {
+	_, err := r.db.Exec(
+		`UPDATE config
+		SET require_admin_totp = ?`,
+		required,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetSoloMode(enabled bool) error {
+	_, err := r.db.Exec(
+		`UPDATE config
+		SET solo_mode = ?`,
+		enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetRegistration(policy, inviteCode, domains string, start, end *int64) error {
+	_, err := r.db.Exec(
+		`UPDATE config
+		SET registration_policy = ?, registration_invite_code = ?, registration_domains = ?, registration_start = from_unixtime(?), registration_end = from_unixtime(?)`,
+		policy, inviteCode, domains, start, end,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
 func (r *repository) GetConfig() (*model.Config, error) {
 	var config model.Config
 	err := r.db.Get(
 		&config,
-		`SELECT ctf_name, unix_timestamp(start_at) as start_at, unix_timestamp(end_at) as end_at, lock_second, lock_duration, lock_count, easy_solves, medium_solves, min_score 
+		`SELECT ctf_name, unix_timestamp(start_at) as start_at, unix_timestamp(end_at) as end_at, lock_second, lock_duration, lock_count, easy_solves, medium_solves, min_score, max_team_size, email_verification, require_admin_totp, solo_mode,
+			registration_policy, registration_invite_code, registration_domains, unix_timestamp(registration_start) as registration_start, unix_timestamp(registration_end) as registration_end
 		FROM config
 		LIMIT 1`,
 	)
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type DashboardRepository interface {
+	CountRegistrations() ([]*model.RegistrationCount, error)
+	CountSubmissions(since int64) ([]*model.SubmissionCount, error)
+	ListRecentSolves(limit int) ([]*model.RecentSolve, error)
+	ListSubmissionLocks() (map[uint32]time.Duration, error)
+}
+
+// CountRegistrations returns the number of registered users for each hour
+func (r *repository) CountRegistrations() ([]*model.RegistrationCount, error) {
+	counts := make([]*model.RegistrationCount, 0)
+	err := r.db.Select(
+		&counts,
+		`SELECT UNIX_TIMESTAMP(DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00')) AS time, COUNT(*) AS count
+		FROM users
+		GROUP BY time
+		ORDER BY time ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return counts, nil
+}
+
+// CountSubmissions returns the number of submissions for each minute since the time
+func (r *repository) CountSubmissions(since int64) ([]*model.SubmissionCount, error) {
+	counts := make([]*model.SubmissionCount, 0)
+	err := r.db.Select(
+		&counts,
+		`SELECT submitted_at DIV 60 * 60 AS time,
+			COALESCE(SUM(is_correct AND is_valid), 0) AS correct,
+			COALESCE(SUM(NOT is_correct), 0) AS wrong,
+			COALESCE(SUM(is_correct AND NOT is_valid), 0) AS duplicate
+		FROM submissions
+		WHERE submitted_at >= ?
+		GROUP BY time
+		ORDER BY time ASC`,
+		since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return counts, nil
+}
+
+func (r *repository) ListRecentSolves(limit int) ([]*model.RecentSolve, error) {
+	solves := make([]*model.RecentSolve, 0)
+	err := r.db.Select(
+		&solves,
+		`SELECT submissions.id, submissions.team_id, teamname, submissions.user_id, username, challenge_id, challenges.name AS challenge_name, submitted_at
+		FROM submissions
+		INNER JOIN teams
+		ON submissions.team_id = teams.id
+		LEFT JOIN users
+		ON submissions.user_id = users.id
+		LEFT JOIN challenges
+		ON submissions.challenge_id = challenges.id
+		WHERE is_valid = TRUE
+		ORDER BY submitted_at DESC, submissions.id DESC
+		LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return solves, nil
+}
+
+// ListSubmissionLocks returns the teams locked by LockSubmission with the remaining time
+func (r *repository) ListSubmissionLocks() (map[uint32]time.Duration, error) {
+	locks := make(map[uint32]time.Duration)
+	var cursor uint64
+	for {
+		keys, next, err := r.redis.Scan(cursor, lockSubmissionPrefix+"*", 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		for _, key := range keys {
+			tid, err := strconv.ParseUint(strings.TrimPrefix(key, lockSubmissionPrefix), 10, 32)
+			if err != nil {
+				continue
+			}
+			ttl, err := r.redis.PTTL(key).Result()
+			if err != nil {
+				return nil, fmt.Errorf("%w", err)
+			}
+			if ttl > 0 {
+				locks[uint32(tid)] = ttl
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return locks, nil
+		}
+	}
+}
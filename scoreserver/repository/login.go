@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoginThrottleRepository counts failed logins in redis. key identifies an account or an IP address
+type LoginThrottleRepository interface {
+	IncrementLoginFailure(key string, expire time.Duration) (int, error)
+	ResetLoginFailure(key string) error
+	LockLogin(key string, duration time.Duration) error
+	GetLoginLock(key string) (time.Duration, error)
+}
+
+func (r *repository) IncrementLoginFailure(key string, expire time.Duration) (int, error) {
+	k := loginFailureKey(key)
+	cnt, err := r.redis.Incr(k).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	err = r.redis.Expire(k, expire).Err()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	return int(cnt), nil
+}
+
+func (r *repository) ResetLoginFailure(key string) error {
+	err := r.redis.Del(loginFailureKey(key)).Err()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) LockLogin(key string, duration time.Duration) error {
+	err := r.redis.Set(loginLockKey(key), "1", duration).Err()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// GetLoginLock returns the remaining time of the lock. it is not positive when the key is not locked
+func (r *repository) GetLoginLock(key string) (time.Duration, error) {
+	ttl, err := r.redis.PTTL(loginLockKey(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	return ttl, nil
+}
+
+func loginFailureKey(key string) string {
+	return fmt.Sprintf("LOGINFAIL:%s", key)
+}
+
+func loginLockKey(key string) string {
+	return fmt.Sprintf("LOGINLOCK:%s", key)
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+// PrivacyRepository is for the data export and the account deletion
+type PrivacyRepository interface {
+	FindUserProfile(uid uint32) (*model.AdminUser, error)
+	ListUserIdentities(uid uint32) ([]*model.Identity, error)
+	ListUserSubmissions(uid uint32) ([]*model.UserSubmission, error)
+	DeleteUser(uid, tid uint32, emptyTeamName, emptyTeamSkeleton string) error
+	IconInUse(path string) (bool, error)
+}
+
+func (r *repository) FindUserProfile(uid uint32) (*model.AdminUser, error) {
+	var user model.AdminUser
+	err := r.db.Get(
+		&user,
+		`SELECT users.id, username, email, team_id, teamname, users.is_hidden, is_admin, ban_reason, email_verified, totp_enabled, users.created_at
+		FROM users
+		INNER JOIN teams
+		ON users.team_id = teams.id
+		WHERE users.id = ?
+		LIMIT 1`,
+		uid,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("user")
+		}
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &user, nil
+}
+
+func (r *repository) ListUserIdentities(uid uint32) ([]*model.Identity, error) {
+	identities := make([]*model.Identity, 0)
+	err := r.db.Select(
+		&identities,
+		`SELECT issuer, subject, email
+		FROM user_identities
+		WHERE user_id = ?
+		ORDER BY id ASC`,
+		uid,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return identities, nil
+}
+
+// ListUserSubmissions returns every submission sent by the user, including the ones for the former teams
+func (r *repository) ListUserSubmissions(uid uint32) ([]*model.UserSubmission, error) {
+	submissions := make([]*model.UserSubmission, 0)
+	err := r.db.Select(
+		&submissions,
+		`SELECT submissions.id, challenge_id, challenges.name AS challenge_name, team_id, flag, submitted_at, is_correct, is_valid
+		FROM submissions
+		LEFT JOIN challenges
+		ON submissions.challenge_id = challenges.id
+		WHERE user_id = ?
+		ORDER BY submitted_at ASC`,
+		uid,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return submissions, nil
+}
+
+// DeleteUser deletes the user of the team in a transaction. sessions, tokens and identities are deleted by the foreign keys,
+// and submissions.user_id becomes null so that the submissions stay in the team.
+// the earliest remaining member becomes the captain, and the team left without members is renamed to emptyTeamName
+func (r *repository) DeleteUser(uid, tid uint32, emptyTeamName, emptyTeamSkeleton string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer tx.Rollback()
+
+	var captainID *uint32
+	if err := tx.Get(&captainID, `SELECT captain_id FROM teams WHERE id = ? FOR UPDATE`, tid); err != nil {
+		if err == sql.ErrNoRows {
+			return model.NotFoundError("team")
+		}
+		return fmt.Errorf("%w", err)
+	}
+
+	res, err := tx.Exec(`DELETE FROM users WHERE id = ? AND team_id = ?`, uid, tid)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if n == 0 {
+		return model.NotFoundError("user")
+	}
+
+	if captainID != nil && *captainID == uid {
+		_, err = tx.Exec(
+			`UPDATE teams
+			SET captain_id = (SELECT id FROM users WHERE team_id = ? ORDER BY created_at ASC, id ASC LIMIT 1)
+			WHERE id = ?`,
+			tid, tid,
+		)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	var empty bool
+	if err := tx.Get(&empty, `SELECT NOT EXISTS(SELECT 1 FROM users WHERE team_id = ?)`, tid); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if empty {
+		_, err = tx.Exec(`UPDATE teams SET teamname = ?, skeleton = ? WHERE id = ?`, emptyTeamName, emptyTeamSkeleton, tid)
+		if err != nil {
+			if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+				return model.DuplicateError("team")
+			}
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// IconInUse returns true when a user or a team uses the icon. icon files are shared because the name is the hash
+func (r *repository) IconInUse(path string) (bool, error) {
+	var used bool
+	err := r.db.Get(
+		&used,
+		`SELECT EXISTS(SELECT 1 FROM users WHERE icon_path = ?) OR EXISTS(SELECT 1 FROM teams WHERE icon_path = ?)`,
+		path, path,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	return used, nil
+}
@@ -13,6 +13,13 @@ type Repository interface {
 	ChallengeRepository
 	ConfigRepository
 	SubmissionRepository
+	APITokenRepository
+	AdminRepository
+	LoginThrottleRepository
+	PrivacyRepository
+	DashboardRepository
+	SubmissionLogRepository
+	AuditLogRepository
 }
 
 type repository struct {
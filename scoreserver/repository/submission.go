@@ -13,8 +13,11 @@ import (
 type SubmissionRepository interface {
 	FindValidSubmission(tid, cid uint32) (*model.Submission, error)
 	InsertSubmission(cid, uid, tid sql.NullInt64, flag string, submit_at int64, is_correct, is_valid bool) error
+	GrantSolve(tid, cid uint32, uid *uint32, submittedAt int64, actorID uint32) error
+	InvalidateSolve(tid, cid uint32) error
 
 	ListValidSubmission(cid uint32) ([]*model.Submission, error)
+	ListCorrectSubmission(cid uint32) ([]*model.Submission, error)
 
 	IncrementWrong(tid uint32, expire time.Duration) (int, error)
 	GetWrongCount(tid uint32) (int, error)
@@ -58,14 +61,82 @@ func (r *repository) InsertSubmission(cid, uid, tid sql.NullInt64, flag string,
 	return nil
 }
 
+// GrantSolve adds a valid submission without a flag. the team row is locked while checking the team has not solved it yet
+func (r *repository) GrantSolve(tid, cid uint32, uid *uint32, submittedAt int64, actorID uint32) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer tx.Rollback()
+
+	var locked uint32
+	if err := tx.Get(&locked, `SELECT id FROM teams WHERE id = ? FOR UPDATE`, tid); err != nil {
+		if err == sql.ErrNoRows {
+			return model.NotFoundError("team")
+		}
+		return fmt.Errorf("%w", err)
+	}
+
+	var solved bool
+	err = tx.Get(
+		&solved,
+		`SELECT EXISTS(SELECT 1 FROM submissions WHERE team_id = ? AND challenge_id = ? AND is_valid = TRUE)`,
+		tid, cid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if solved {
+		return model.DuplicateError("solve")
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO
+		submissions(id, user_id, team_id, challenge_id, flag, submitted_at, is_correct, is_valid, granted_by)
+		VALUES (?, ?, ?, ?, '', ?, TRUE, TRUE, ?)`,
+		r.newID(), uid, tid, cid, submittedAt, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// InvalidateSolve makes the valid submission of the team for the challenge invalid. the submission itself is kept
+func (r *repository) InvalidateSolve(tid, cid uint32) error {
+	res, err := r.db.Exec(
+		`UPDATE submissions
+		SET is_valid = FALSE
+		WHERE team_id = ? AND challenge_id = ? AND is_valid = TRUE`,
+		tid, cid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if n == 0 {
+		return model.NotFoundError("solve")
+	}
+	return nil
+}
+
+// ListValidSubmission returns the solves of the challenge. solves of hidden teams are not counted
 func (r *repository) ListValidSubmission(cid uint32) ([]*model.Submission, error) {
 	submissons := make([]*model.Submission, 0)
 	err := r.db.Select(
 		&submissons,
-		`SELECT *
+		`SELECT submissions.*
 		FROM submissions
+		INNER JOIN teams
+		ON submissions.team_id = teams.id AND NOT teams.is_hidden
 		WHERE challenge_id = ? AND is_valid = TRUE
-		ORDER BY created_at ASC
+		ORDER BY submissions.submitted_at ASC, submissions.created_at ASC
 		`,
 		cid,
 	)
@@ -76,6 +147,23 @@ func (r *repository) ListValidSubmission(cid uint32) ([]*model.Submission, error
 	return submissons, nil
 }
 
+func (r *repository) ListCorrectSubmission(cid uint32) ([]*model.Submission, error) {
+	submissons := make([]*model.Submission, 0)
+	err := r.db.Select(
+		&submissons,
+		`SELECT *
+		FROM submissions
+		WHERE challenge_id = ? AND is_correct = TRUE
+		ORDER BY submitted_at ASC, created_at ASC
+		`,
+		cid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return submissons, nil
+}
+
 func (r *repository) IncrementWrong(tid uint32, expire time.Duration) (int, error) {
 	key := wrongCountKey(tid)
 	cnt, err := r.redis.Incr(key).Result()
@@ -132,6 +220,8 @@ func wrongCountKey(id uint32) string {
 	return fmt.Sprintf("WRONG%d", id)
 }
 
+const lockSubmissionPrefix = "LOCK"
+
 func lockSubmissionKey(id uint32) string {
-	return fmt.Sprintf("LOCK%d", id)
+	return fmt.Sprintf("%s%d", lockSubmissionPrefix, id)
 }
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type SubmissionLogRepository interface {
+	ListSubmissionLogs(filter *model.SubmissionFilter, cursor *model.SubmissionCursor, limit int) ([]*model.SubmissionLog, error)
+}
+
+// ListSubmissionLogs returns the submissions matching the filter, the newest first.
+// when cursor is not nil, the submissions after the cursor (older ones) are returned
+func (r *repository) ListSubmissionLogs(filter *model.SubmissionFilter, cursor *model.SubmissionCursor, limit int) ([]*model.SubmissionLog, error) {
+	conds := []string{"TRUE"}
+	args := make([]interface{}, 0)
+	if filter.TeamID != nil {
+		conds = append(conds, "submissions.team_id = ?")
+		args = append(args, *filter.TeamID)
+	}
+	if filter.UserID != nil {
+		conds = append(conds, "submissions.user_id = ?")
+		args = append(args, *filter.UserID)
+	}
+	if filter.ChallengeID != nil {
+		conds = append(conds, "submissions.challenge_id = ?")
+		args = append(args, *filter.ChallengeID)
+	}
+	if filter.IsCorrect != nil {
+		conds = append(conds, "submissions.is_correct = ?")
+		args = append(args, *filter.IsCorrect)
+	}
+	if filter.IsValid != nil {
+		conds = append(conds, "submissions.is_valid = ?")
+		args = append(args, *filter.IsValid)
+	}
+	if filter.Since != nil {
+		conds = append(conds, "submissions.submitted_at >= ?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		conds = append(conds, "submissions.submitted_at < ?")
+		args = append(args, *filter.Until)
+	}
+	if cursor != nil {
+		conds = append(conds, "(submissions.submitted_at < ? OR (submissions.submitted_at = ? AND submissions.id < ?))")
+		args = append(args, cursor.SubmittedAt, cursor.SubmittedAt, cursor.ID)
+	}
+	args = append(args, limit)
+
+	logs := make([]*model.SubmissionLog, 0)
+	err := r.db.Select(
+		&logs,
+		fmt.Sprintf(`SELECT submissions.id, submissions.challenge_id, challenges.name AS challenge_name,
+			submissions.user_id, username, submissions.team_id, teamname,
+			submissions.flag, submitted_at, is_correct, is_valid, granted_by
+		FROM submissions
+		LEFT JOIN challenges
+		ON submissions.challenge_id = challenges.id
+		LEFT JOIN users
+		ON submissions.user_id = users.id
+		LEFT JOIN teams
+		ON submissions.team_id = teams.id
+		WHERE %s
+		ORDER BY submissions.submitted_at DESC, submissions.id DESC
+		LIMIT ?`, strings.Join(conds, " AND ")),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return logs, nil
+}
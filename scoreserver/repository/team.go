@@ -10,15 +10,26 @@ import (
 
 type TeamRepository interface {
 	FindTeamByName(teamName string) (*model.Team, error)
+	FindTeamBySkeleton(skeleton string) (*model.Team, error)
 	FindTeamByToken(token string) (*model.Team, error)
 	FindUserTeam(uid uint32) (*model.Team, error)
 
 	FindTeamByID(id uint32) (*model.Team, error)
-	UpdateTeamName(tid uint32, teamName string) error
+	UpdateTeamName(tid uint32, teamName, skeleton string) error
 	ListTeams(visibleOnly bool) ([]*model.Team, error)
 
-	CreateTeam(teamName, token, countryCode string) (uint32, error)
+	CreateTeam(teamName, skeleton, token, countryCode string) (uint32, error)
 	SetCountryCode(tid uint32, counrtyCode string) error
+	SetTeamCaptain(tid uint32, uid *uint32) error
+	SetTeamIcon(tid uint32, iconPath *string) error
+	UpdateTeamToken(tid uint32, token string) error
+
+	AddMembershipHistory(uid, tid uint32, action string, actorID *uint32) error
+	MoveToNewTeam(uid, tid uint32, action string, actorID *uint32, teamName, skeleton, token, countryCode string) (uint32, error)
+	MoveToTeam(uid, from, to uint32, actorID *uint32) error
+	FillTeamCaptains() (int64, error)
+	ListTeamsWithoutSkeleton() ([]*model.Team, error)
+	SetTeamSkeleton(tid uint32, skeleton string) error
 }
 
 func (r *repository) FindTeamByName(teamName string) (*model.Team, error) {
@@ -39,6 +50,25 @@ func (r *repository) FindTeamByName(teamName string) (*model.Team, error) {
 	return &team, nil
 }
 
+// FindTeamBySkeleton finds the team whose name looks the same. see the service for the skeleton
+func (r *repository) FindTeamBySkeleton(skeleton string) (*model.Team, error) {
+	var team model.Team
+	err := r.db.Get(
+		&team,
+		`SELECT *
+		FROM teams
+		WHERE skeleton = ?`,
+		skeleton,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("team")
+		}
+		return nil, err
+	}
+	return &team, nil
+}
+
 func (r *repository) FindTeamByToken(token string) (*model.Team, error) {
 	var team model.Team
 	err := r.db.Get(
@@ -129,13 +159,13 @@ func (r *repository) ListTeams(visibleOnly bool) ([]*model.Team, error) {
 	return teams, nil
 }
 
-func (r *repository) CreateTeam(teamName, token, countryCode string) (uint32, error) {
+func (r *repository) CreateTeam(teamName, skeleton, token, countryCode string) (uint32, error) {
 	id := r.newID()
 	_, err := r.db.Exec(
 		`INSERT INTO
-		teams(id, teamname, token, country_code)
-		VALUES (?, ?, ?, ?)`,
-		id, teamName, token, countryCode,
+		teams(id, teamname, skeleton, token, country_code)
+		VALUES (?, ?, ?, ?, ?)`,
+		id, teamName, skeleton, token, countryCode,
 	)
 	if err != nil {
 		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
@@ -162,7 +192,8 @@ func (r *repository) setUsers(team model.Team) (model.Team, error) {
 		&users,
 		`SELECT *
 		FROM users
-		WHERE team_id = ?`,
+		WHERE team_id = ?
+		ORDER BY created_at ASC`,
 		team.ID,
 	)
 
@@ -220,12 +251,12 @@ func (r *repository) setValidSubmissions(teams []*model.Team) ([]*model.Team, er
 	return teams, nil
 }
 
-func (r *repository) UpdateTeamName(tid uint32, teamName string) error {
+func (r *repository) UpdateTeamName(tid uint32, teamName, skeleton string) error {
 	_, err := r.db.Exec(
 		`UPDATE teams
-		SET teamname = ?
+		SET teamname = ?, skeleton = ?
 		WHERE id = ?`,
-		teamName, tid,
+		teamName, skeleton, tid,
 	)
 	if err != nil {
 		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
@@ -235,3 +266,264 @@ func (r *repository) UpdateTeamName(tid uint32, teamName string) error {
 	}
 	return nil
 }
+
+func (r *repository) SetTeamCaptain(tid uint32, uid *uint32) error {
+	_, err := r.db.Exec(
+		`UPDATE teams
+		SET captain_id = ?
+		WHERE id = ?`,
+		uid, tid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetTeamIcon(tid uint32, iconPath *string) error {
+	_, err := r.db.Exec(
+		`UPDATE teams
+		SET icon_path = ?
+		WHERE id = ?`,
+		iconPath, tid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) UpdateTeamToken(tid uint32, token string) error {
+	_, err := r.db.Exec(
+		`UPDATE teams
+		SET token = ?
+		WHERE id = ?`,
+		token, tid,
+	)
+	if err != nil {
+		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+			return model.DuplicateError("token")
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) AddMembershipHistory(uid, tid uint32, action string, actorID *uint32) error {
+	_, err := r.db.Exec(
+		`INSERT INTO
+		team_membership_histories(id, user_id, team_id, action, actor_id)
+		VALUES (?, ?, ?, ?, ?)`,
+		r.newID(), uid, tid, action, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// MoveToNewTeam moves the user from the team to a new team which has only the user, in a transaction.
+// action is recorded in the history of the old team. when the user was the captain of the old team,
+// the earliest remaining member becomes the captain
+func (r *repository) MoveToNewTeam(uid, tid uint32, action string, actorID *uint32, teamName, skeleton, token, countryCode string) (uint32, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	defer tx.Rollback()
+
+	var captainID *uint32
+	if err := tx.Get(&captainID, `SELECT captain_id FROM teams WHERE id = ? FOR UPDATE`, tid); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, model.NotFoundError("team")
+		}
+		return 0, fmt.Errorf("%w", err)
+	}
+	var member bool
+	if err := tx.Get(&member, `SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND team_id = ?)`, uid, tid); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	if !member {
+		return 0, model.NotFoundError("member")
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO
+		team_membership_histories(id, user_id, team_id, action, actor_id)
+		VALUES (?, ?, ?, ?, ?)`,
+		r.newID(), uid, tid, action, actorID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	newID := r.newID()
+	_, err = tx.Exec(
+		`INSERT INTO
+		teams(id, teamname, skeleton, token, country_code, captain_id)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		newID, teamName, skeleton, token, countryCode, uid,
+	)
+	if err != nil {
+		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+			return 0, model.DuplicateError("team")
+		}
+		return 0, fmt.Errorf("%w", err)
+	}
+	if _, err := tx.Exec(`UPDATE users SET team_id = ? WHERE id = ?`, newID, uid); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	_, err = tx.Exec(
+		`INSERT INTO
+		team_membership_histories(id, user_id, team_id, action, actor_id)
+		VALUES (?, ?, ?, ?, ?)`,
+		r.newID(), uid, newID, model.MembershipCreate, actorID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	if captainID != nil && *captainID == uid {
+		_, err = tx.Exec(
+			`UPDATE teams
+			SET captain_id = (SELECT id FROM users WHERE team_id = ? ORDER BY created_at ASC, id ASC LIMIT 1)
+			WHERE id = ?`,
+			tid, tid,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	return newID, nil
+}
+
+// MoveToTeam moves the user from the team to another existing team, in a transaction.
+// the captain of the old team is passed to the earliest remaining member as MoveToNewTeam does,
+// and the user becomes the captain of the new team when it has no captain
+func (r *repository) MoveToTeam(uid, from, to uint32, actorID *uint32) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer tx.Rollback()
+
+	// lock the teams in the order of the id so that two moves between the same teams do not deadlock
+	var teams []struct {
+		ID        uint32  `db:"id"`
+		CaptainID *uint32 `db:"captain_id"`
+	}
+	if err := tx.Select(&teams, `SELECT id, captain_id FROM teams WHERE id IN (?, ?) ORDER BY id FOR UPDATE`, from, to); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if len(teams) != 2 {
+		return model.NotFoundError("team")
+	}
+	var oldCaptainID, newCaptainID *uint32
+	for _, t := range teams {
+		if t.ID == from {
+			oldCaptainID = t.CaptainID
+		} else {
+			newCaptainID = t.CaptainID
+		}
+	}
+
+	res, err := tx.Exec(`UPDATE users SET team_id = ? WHERE id = ? AND team_id = ?`, to, uid, from)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if n == 0 {
+		return model.NotFoundError("member")
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO
+		team_membership_histories(id, user_id, team_id, action, actor_id)
+		VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`,
+		r.newID(), uid, from, model.MembershipLeave, actorID,
+		r.newID(), uid, to, model.MembershipJoin, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if oldCaptainID != nil && *oldCaptainID == uid {
+		_, err = tx.Exec(
+			`UPDATE teams
+			SET captain_id = (SELECT id FROM users WHERE team_id = ? ORDER BY created_at ASC, id ASC LIMIT 1)
+			WHERE id = ?`,
+			from, from,
+		)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+	if newCaptainID == nil {
+		if _, err := tx.Exec(`UPDATE teams SET captain_id = ? WHERE id = ?`, uid, to); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// FillTeamCaptains makes the earliest member the captain of the teams without a captain,
+// i.e. the teams registered before captains were introduced. it returns the number of the updated teams
+func (r *repository) FillTeamCaptains() (int64, error) {
+	res, err := r.db.Exec(
+		`UPDATE teams
+		SET captain_id = (SELECT id FROM users WHERE users.team_id = teams.id ORDER BY created_at ASC, id ASC LIMIT 1)
+		WHERE captain_id IS NULL
+		AND EXISTS(SELECT 1 FROM users WHERE users.team_id = teams.id)`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	return n, nil
+}
+
+// ListTeamsWithoutSkeleton returns the teams registered before skeletons were introduced
+func (r *repository) ListTeamsWithoutSkeleton() ([]*model.Team, error) {
+	teams := make([]*model.Team, 0)
+	err := r.db.Select(
+		&teams,
+		`SELECT *
+		FROM teams
+		WHERE skeleton IS NULL
+		ORDER BY created_at ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return teams, nil
+}
+
+func (r *repository) SetTeamSkeleton(tid uint32, skeleton string) error {
+	_, err := r.db.Exec(
+		`UPDATE teams
+		SET skeleton = ?
+		WHERE id = ?`,
+		skeleton, tid,
+	)
+	if err != nil {
+		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+			return model.DuplicateError("team")
+		}
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -11,30 +12,88 @@ import (
 )
 
 type UserRepository interface {
-	RegisterUser(username, email, passwordHash string, tid uint32) (uint32, error)
+	RegisterUser(username, email, passwordHash string, tid uint32, maxTeamSize int, emailVerified bool, verification *model.EmailVerificationToken) (uint32, error)
 	UpdateUserPassword(uid uint32, passwordHash string) error
+	UpdateUserName(uid uint32, username string) error
+	UpdateUserEmail(uid uint32, email string) error
+	SetUserIcon(uid uint32, iconPath *string) error
+	SetEmailVerified(uid uint32, verified bool) error
 
 	FindUserByID(id uint32) (*model.User, error)
 	FindUserByName(username string) (*model.User, error)
 	FindUserByEmail(email string) (*model.User, error)
 	FindUserByToken(token string) (*model.User, error)
+	FindUserByIdentity(issuer, subject string) (*model.User, error)
+	AddUserIdentity(uid uint32, identity *model.Identity) error
 
 	RevokeToken(token string) error
 	RevokeTokenByUserID(uid uint32) error
-	NewToken(uid uint32, token string, expiresAt uint64) error
+	RevokeTokenByUserIDExcept(uid uint32, token string) error
+	NewToken(uid uint32, token string, expiresAt uint64, mfa bool, info *model.SessionInfo) error
+	FindToken(token string) (*model.Token, error)
+	ListTokens(uid uint32) ([]*model.Token, error)
+	RevokeTokenByID(uid, id uint32) error
+	TouchToken(token string, interval time.Duration) error
 
-	FindUserByPasswordResetToken(token string) (*model.User, error)
+	FindUserByPasswordResetToken(tokenHash string) (*model.User, error)
 	RevokePasswordResetTokenByUserID(uid uint32) error
-	NewPasswordResetToken(uid uint32, token string, expiresAt uint64) error
+	NewPasswordResetToken(uid uint32, tokenHash string, expiresAt uint64) error
+	LockPasswordResetMail(email string, duration time.Duration) (bool, error)
+	IncrementPasswordResetRequest(ip string, expire time.Duration) (int, error)
+
+	FindEmailChangeToken(token string) (*model.EmailChangeToken, error)
+	RevokeEmailChangeTokenByUserID(uid uint32) error
+	NewEmailChangeToken(uid uint32, email, token string, expiresAt uint64) error
+
+	FindUserByEmailVerificationToken(tokenHash string) (*model.User, error)
+	RevokeEmailVerificationTokenByUserID(uid uint32) error
+	NewEmailVerificationToken(uid uint32, tokenHash string, expiresAt uint64) error
+	LockVerificationMail(uid uint32, duration time.Duration) (bool, error)
+	VerifyUsersWithoutVerificationToken() (int64, error)
+
+	SetTOTPSecret(uid uint32, secret *string) error
+	EnableTOTP(uid uint32, enabled bool) error
+	UpdateTOTPStep(uid uint32, step uint64) (bool, error)
+	ReplaceRecoveryCodes(uid uint32, codeHashes []string) error
+	UseRecoveryCode(uid uint32, codeHash string) (bool, error)
 }
 
-func (r *repository) RegisterUser(username, email, passwordHash string, tid uint32) (uint32, error) {
+// RegisterUser adds the user to the team. when maxTeamSize > 0, the team row is locked
+// while counting the members so that concurrent registrations can not exceed the limit.
+// verification is the email verification token of the user, inserted in the same transaction. UserID is filled here
+func (r *repository) RegisterUser(username, email, passwordHash string, tid uint32, maxTeamSize int, emailVerified bool, verification *model.EmailVerificationToken) (uint32, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	defer tx.Rollback()
+
+	if maxTeamSize > 0 {
+		var locked uint32
+		err = tx.Get(&locked, `SELECT id FROM teams WHERE id = ? FOR UPDATE`, tid)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return 0, model.NotFoundError("team")
+			}
+			return 0, fmt.Errorf("%w", err)
+		}
+
+		var count int
+		err = tx.Get(&count, `SELECT COUNT(*) FROM users WHERE team_id = ?`, tid)
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+		if count >= maxTeamSize {
+			return 0, model.TeamFullError("team")
+		}
+	}
+
 	id := r.newID()
-	_, err := r.db.Exec(
+	_, err = tx.Exec(
 		`INSERT INTO
-		users(id, username, email, password_hash, icon_path, team_id, is_hidden, is_admin)
-		VALUES (?, ?, ?, ?, NULL, ?, FALSE, FALSE)`,
-		id, username, email, passwordHash, tid,
+		users(id, username, email, password_hash, icon_path, team_id, is_hidden, is_admin, email_verified)
+		VALUES (?, ?, ?, ?, NULL, ?, FALSE, FALSE, ?)`,
+		id, username, email, passwordHash, tid, emailVerified,
 	)
 	if err != nil {
 		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
@@ -42,6 +101,22 @@ func (r *repository) RegisterUser(username, email, passwordHash string, tid uint
 		}
 		return 0, err
 	}
+
+	if verification != nil {
+		verification.UserID = id
+		_, err = tx.Exec(
+			`INSERT INTO email_verification_tokens(user_id, token_hash, expires_at, revoked)
+			VALUES (?, ?, ?, FALSE)`,
+			verification.UserID, verification.TokenHash, verification.ExpiresAt,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
 	return id, nil
 }
 
@@ -58,11 +133,69 @@ func (r *repository) UpdateUserPassword(uid uint32, passwordHash string) error {
 	return nil
 }
 
+func (r *repository) UpdateUserName(uid uint32, username string) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET username = ?
+		WHERE id = ?`,
+		username, uid,
+	)
+	if err != nil {
+		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+			return model.DuplicateError("user")
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdateUserEmail(uid uint32, email string) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET email = ?
+		WHERE id = ?`,
+		email, uid,
+	)
+	if err != nil {
+		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+			return model.DuplicateError("email")
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) SetUserIcon(uid uint32, iconPath *string) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET icon_path = ?
+		WHERE id = ?`,
+		iconPath, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) SetEmailVerified(uid uint32, verified bool) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET email_verified = ?
+		WHERE id = ?`,
+		verified, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
 func (r *repository) FindUserByID(uid uint32) (*model.User, error) {
 	var user model.User
 	err := r.db.Get(
 		&user,
-		`SELECT id, username, email, password_hash, team_id, is_hidden, is_admin, icon_path
+		`SELECT id, username, email, password_hash, team_id, is_hidden, is_admin, icon_path, email_verified, totp_secret, totp_enabled, totp_last_step, ban_reason
 		FROM users
 		WHERE id = ?
 		LIMIT 1`,
@@ -81,7 +214,7 @@ func (r *repository) FindUserByName(username string) (*model.User, error) {
 	var user model.User
 	err := r.db.Get(
 		&user,
-		`SELECT id, username, email, password_hash, team_id, is_hidden, is_admin, icon_path
+		`SELECT id, username, email, password_hash, team_id, is_hidden, is_admin, icon_path, email_verified, totp_secret, totp_enabled, totp_last_step, ban_reason
 		FROM users
 		WHERE username = ?
 		LIMIT 1`,
@@ -100,7 +233,7 @@ func (r *repository) FindUserByEmail(email string) (*model.User, error) {
 	var user model.User
 	err := r.db.Get(
 		&user,
-		`SELECT id, username, email, password_hash, team_id, is_hidden, is_admin, icon_path
+		`SELECT id, username, email, password_hash, team_id, is_hidden, is_admin, icon_path, email_verified, totp_secret, totp_enabled, totp_last_step, ban_reason
 		FROM users
 		WHERE email = ?
 		LIMIT 1`,
@@ -115,13 +248,51 @@ func (r *repository) FindUserByEmail(email string) (*model.User, error) {
 	return &user, nil
 }
 
+func (r *repository) FindUserByIdentity(issuer, subject string) (*model.User, error) {
+	var user model.User
+	err := r.db.Get(
+		&user,
+		`SELECT users.id, username, users.email, password_hash, team_id, is_hidden, is_admin, icon_path, email_verified, totp_secret, totp_enabled, totp_last_step, ban_reason
+		FROM users
+		INNER JOIN user_identities
+		ON users.id = user_identities.user_id
+		AND user_identities.issuer = ?
+		AND user_identities.subject = ?
+		LIMIT 1`,
+		issuer, subject,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("user")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *repository) AddUserIdentity(uid uint32, identity *model.Identity) error {
+	_, err := r.db.Exec(
+		`INSERT INTO user_identities(id, user_id, issuer, subject, email)
+		VALUES (?, ?, ?, ?, ?)`,
+		r.newID(), uid, identity.Issuer, identity.Subject, identity.Email,
+	)
+	if err != nil {
+		if mysqlerr, ok := err.(*mysql.MySQLError); ok && mysqlerr.Number == 1062 {
+			return model.DuplicateError("identity")
+		}
+		return err
+	}
+	return nil
+}
+
 func (r *repository) FindUserByToken(token string) (*model.User, error) {
 	var user model.User
 	now := time.Now().Unix()
 
 	err := r.db.Get(
 		&user,
-		`SELECT id, username, email, password_hash, team_id, is_hidden, is_admin, icon_path 
+		`SELECT users.id, users.username, users.email, users.password_hash, users.team_id, users.is_hidden, users.is_admin, users.icon_path,
+			users.email_verified, users.totp_secret, users.totp_enabled, users.totp_last_step, users.ban_reason
 		FROM users
 		INNER JOIN tokens
 		ON users.id = tokens.user_id
@@ -160,16 +331,120 @@ func (r *repository) RevokeTokenByUserID(uid uint32) error {
 	return err
 }
 
-func (r *repository) NewToken(uid uint32, token string, expiresAt uint64) error {
+func (r *repository) RevokeTokenByUserIDExcept(uid uint32, token string) error {
 	_, err := r.db.Exec(
-		`INSERT INTO tokens(user_id, token, expires_at, revoked)
-		VALUES (?, ?, ?, FALSE)`,
-		uid, token, expiresAt,
+		`UPDATE tokens
+		SET revoked = TRUE
+		WHERE user_id = ? AND token <> ?`,
+		uid, token,
 	)
 	return err
 }
 
-func (r *repository) FindUserByPasswordResetToken(token string) (*model.User, error) {
+// truncateUserAgent fits the user agent to tokens.user_agent, which is VARCHAR(255) in characters.
+// invalid UTF-8 is replaced because MySQL rejects it, and the string is cut on a character boundary
+func truncateUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "\uFFFD")
+	n := 0
+	for i := range userAgent {
+		if n == 255 {
+			return userAgent[:i]
+		}
+		n++
+	}
+	return userAgent
+}
+
+func (r *repository) NewToken(uid uint32, token string, expiresAt uint64, mfa bool, info *model.SessionInfo) error {
+	if info == nil {
+		info = &model.SessionInfo{}
+	}
+	userAgent := truncateUserAgent(info.UserAgent)
+	_, err := r.db.Exec(
+		`INSERT INTO tokens(id, user_id, token, expires_at, revoked, mfa, ip, user_agent, last_seen_at)
+		VALUES (?, ?, ?, ?, FALSE, ?, ?, ?, ?)`,
+		r.newID(), uid, token, expiresAt, mfa, info.IP, userAgent, time.Now().Unix(),
+	)
+	return err
+}
+
+func (r *repository) FindToken(token string) (*model.Token, error) {
+	var t model.Token
+	now := time.Now().Unix()
+
+	err := r.db.Get(
+		&t,
+		`SELECT id, user_id, token, expires_at, mfa, ip, user_agent, last_seen_at, created_at, updated_at
+		FROM tokens
+		WHERE token = ? AND expires_at > ? AND revoked = FALSE
+		LIMIT 1`,
+		token, now,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("token")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTokens returns the active sessions of the user, most recently used first
+func (r *repository) ListTokens(uid uint32) ([]*model.Token, error) {
+	tokens := make([]*model.Token, 0)
+	now := time.Now().Unix()
+
+	err := r.db.Select(
+		&tokens,
+		`SELECT id, user_id, token, expires_at, mfa, ip, user_agent, last_seen_at, created_at, updated_at
+		FROM tokens
+		WHERE user_id = ? AND expires_at > ? AND revoked = FALSE
+		ORDER BY last_seen_at DESC`,
+		uid, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeTokenByID revokes the session only when it belongs to the user
+func (r *repository) RevokeTokenByID(uid, id uint32) error {
+	res, err := r.db.Exec(
+		`UPDATE tokens
+		SET revoked = TRUE
+		WHERE id = ? AND user_id = ? AND revoked = FALSE`,
+		id, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if n == 0 {
+		return model.NotFoundError("token")
+	}
+	return nil
+}
+
+// TouchToken updates last_seen_at at most once per interval so that every request does not write the row
+func (r *repository) TouchToken(token string, interval time.Duration) error {
+	now := time.Now()
+	_, err := r.db.Exec(
+		`UPDATE tokens
+		SET last_seen_at = ?
+		WHERE token = ? AND (last_seen_at IS NULL OR last_seen_at < ?)`,
+		now.Unix(), token, now.Add(-interval).Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) FindUserByPasswordResetToken(tokenHash string) (*model.User, error) {
 	var user model.User
 	now := time.Now().Unix()
 
@@ -179,11 +454,11 @@ func (r *repository) FindUserByPasswordResetToken(token string) (*model.User, er
 		FROM users
 		INNER JOIN password_reset_tokens
 		ON users.id = password_reset_tokens.user_id
-		AND password_reset_tokens.token = ?
+		AND password_reset_tokens.token_hash = ?
 		AND password_reset_tokens.expires_at > ?
 		AND password_reset_tokens.revoked = FALSE
 		LIMIT 1`,
-		token, now,
+		tokenHash, now,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -204,11 +479,251 @@ func (r *repository) RevokePasswordResetTokenByUserID(uid uint32) error {
 	return err
 }
 
-func (r *repository) NewPasswordResetToken(uid uint32, token string, expiresAt uint64) error {
+func (r *repository) NewPasswordResetToken(uid uint32, tokenHash string, expiresAt uint64) error {
 	_, err := r.db.Exec(
-		`INSERT INTO password_reset_tokens(user_id, token, expires_at, revoked)
+		`INSERT INTO password_reset_tokens(user_id, token_hash, expires_at, revoked)
 		VALUES (?, ?, ?, FALSE)`,
-		uid, token, expiresAt,
+		uid, tokenHash, expiresAt,
 	)
 	return err
 }
+
+func (r *repository) FindEmailChangeToken(token string) (*model.EmailChangeToken, error) {
+	var t model.EmailChangeToken
+	now := time.Now().Unix()
+
+	err := r.db.Get(
+		&t,
+		`SELECT *
+		FROM email_change_tokens
+		WHERE token = ? AND expires_at > ? AND revoked = FALSE
+		LIMIT 1`,
+		token, now,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("token")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *repository) RevokeEmailChangeTokenByUserID(uid uint32) error {
+	_, err := r.db.Exec(
+		`UPDATE email_change_tokens
+		SET revoked = TRUE
+		WHERE user_id = ?`,
+		uid,
+	)
+	return err
+}
+
+func (r *repository) NewEmailChangeToken(uid uint32, email, token string, expiresAt uint64) error {
+	_, err := r.db.Exec(
+		`INSERT INTO email_change_tokens(user_id, email, token, expires_at, revoked)
+		VALUES (?, ?, ?, ?, FALSE)`,
+		uid, email, token, expiresAt,
+	)
+	return err
+}
+
+func (r *repository) FindUserByEmailVerificationToken(tokenHash string) (*model.User, error) {
+	var user model.User
+	now := time.Now().Unix()
+
+	err := r.db.Get(
+		&user,
+		`SELECT users.*
+		FROM users
+		INNER JOIN email_verification_tokens
+		ON users.id = email_verification_tokens.user_id
+		AND email_verification_tokens.token_hash = ?
+		AND email_verification_tokens.expires_at > ?
+		AND email_verification_tokens.revoked = FALSE
+		LIMIT 1`,
+		tokenHash, now,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NotFoundError("user")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *repository) RevokeEmailVerificationTokenByUserID(uid uint32) error {
+	_, err := r.db.Exec(
+		`UPDATE email_verification_tokens
+		SET revoked = TRUE
+		WHERE user_id = ?`,
+		uid,
+	)
+	return err
+}
+
+func (r *repository) NewEmailVerificationToken(uid uint32, tokenHash string, expiresAt uint64) error {
+	_, err := r.db.Exec(
+		`INSERT INTO email_verification_tokens(user_id, token_hash, expires_at, revoked)
+		VALUES (?, ?, ?, FALSE)`,
+		uid, tokenHash, expiresAt,
+	)
+	return err
+}
+
+// LockVerificationMail returns false when a verification mail was sent to the user within the duration
+func (r *repository) LockVerificationMail(uid uint32, duration time.Duration) (bool, error) {
+	ok, err := r.redis.SetNX(verificationMailKey(uid), "1", duration).Result()
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	return ok, nil
+}
+
+// VerifyUsersWithoutVerificationToken regards the users who have never been sent a verification mail as verified,
+// i.e. the users registered while the email verification was disabled. it returns the number of the updated users
+func (r *repository) VerifyUsersWithoutVerificationToken() (int64, error) {
+	res, err := r.db.Exec(
+		`UPDATE users
+		SET email_verified = TRUE
+		WHERE email_verified = FALSE
+		AND NOT EXISTS(SELECT 1 FROM email_verification_tokens WHERE email_verification_tokens.user_id = users.id)`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	return n, nil
+}
+
+func verificationMailKey(id uint32) string {
+	return fmt.Sprintf("VERIFYMAIL%d", id)
+}
+
+// LockPasswordResetMail returns false when a password reset mail was requested for the email within the duration.
+// the email is locked even if it is not registered
+func (r *repository) LockPasswordResetMail(email string, duration time.Duration) (bool, error) {
+	ok, err := r.redis.SetNX(passwordResetMailKey(email), "1", duration).Result()
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	return ok, nil
+}
+
+// IncrementPasswordResetRequest counts password reset requests from the IP address
+func (r *repository) IncrementPasswordResetRequest(ip string, expire time.Duration) (int, error) {
+	key := passwordResetIPKey(ip)
+	cnt, err := r.redis.Incr(key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	err = r.redis.Expire(key, expire).Err()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	return int(cnt), nil
+}
+
+func passwordResetMailKey(email string) string {
+	return fmt.Sprintf("RESETMAIL:%s", strings.ToLower(email))
+}
+
+func passwordResetIPKey(ip string) string {
+	return fmt.Sprintf("RESETIP:%s", ip)
+}
+
+// SetTOTPSecret starts a new enrollment. TOTP stays disabled until EnableTOTP
+func (r *repository) SetTOTPSecret(uid uint32, secret *string) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET totp_secret = ?, totp_enabled = FALSE, totp_last_step = 0
+		WHERE id = ?`,
+		secret, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *repository) EnableTOTP(uid uint32, enabled bool) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		SET totp_enabled = ?
+		WHERE id = ?`,
+		enabled, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// UpdateTOTPStep records the time step of an accepted code.
+// it returns false when the step or a later one is already used, so that a code can not be replayed
+func (r *repository) UpdateTOTPStep(uid uint32, step uint64) (bool, error) {
+	res, err := r.db.Exec(
+		`UPDATE users
+		SET totp_last_step = ?
+		WHERE id = ? AND totp_last_step < ?`,
+		step, uid, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	return n == 1, nil
+}
+
+func (r *repository) ReplaceRecoveryCodes(uid uint32, codeHashes []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, uid); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	for _, h := range codeHashes {
+		_, err := tx.Exec(
+			`INSERT INTO totp_recovery_codes(user_id, code_hash, used)
+			VALUES (?, ?, FALSE)`,
+			uid, h,
+		)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the code as used and returns false when it is not available
+func (r *repository) UseRecoveryCode(uid uint32, codeHash string) (bool, error) {
+	res, err := r.db.Exec(
+		`UPDATE totp_recovery_codes
+		SET used = TRUE
+		WHERE user_id = ? AND code_hash = ? AND used = FALSE`,
+		uid, codeHash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func (s *server) adminUsersHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		users, err := s.app.SearchUsers(c.QueryParam("q"))
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"users": users,
+		})
+	}
+}
+
+func (s *server) adminTeamsHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		teams, err := s.app.SearchTeams(c.QueryParam("q"))
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"teams": teams,
+		})
+	}
+}
+
+func (s *server) adminSetUserHiddenHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			UserID   uint32 `json:"user_id"`
+			IsHidden bool   `json:"is_hidden"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditUser(c, req.UserID)
+		if err := s.app.SetUserHidden(req.UserID, req.IsHidden); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": UpdateUserMessage,
+		})
+	}
+}
+
+func (s *server) adminBanUserHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			UserID uint32 `json:"user_id"`
+			Reason string `json:"reason"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditUser(c, req.UserID)
+		if err := s.app.BanUser(c.User, req.UserID, req.Reason); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": BanUserMessage,
+		})
+	}
+}
+
+func (s *server) adminUnbanUserHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			UserID uint32 `json:"user_id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditUser(c, req.UserID)
+		if err := s.app.UnbanUser(req.UserID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": UnbanUserMessage,
+		})
+	}
+}
+
+func (s *server) adminSetUserAdminHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			UserID  uint32 `json:"user_id"`
+			IsAdmin bool   `json:"is_admin"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditUser(c, req.UserID)
+		if err := s.app.SetUserAdmin(c.User, req.UserID, req.IsAdmin); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": UpdateUserMessage,
+		})
+	}
+}
+
+func (s *server) adminMoveUserHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			UserID uint32 `json:"user_id"`
+			TeamID uint32 `json:"team_id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditUser(c, req.UserID)
+		if err := s.app.MoveUser(c.User, req.UserID, req.TeamID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": MoveUserMessage,
+		})
+	}
+}
+
+func (s *server) adminSetTeamHiddenHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			TeamID   uint32 `json:"team_id"`
+			IsHidden bool   `json:"is_hidden"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditTeam(c, req.TeamID)
+		if err := s.app.SetTeamHidden(req.TeamID, req.IsHidden); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": UpdateTeamMessage,
+		})
+	}
+}
+
+func (s *server) adminDeleteTeamHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			TeamID uint32 `json:"team_id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditTeam(c, req.TeamID)
+		if err := s.app.DeleteTeam(c.User, req.TeamID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": DeleteTeamMessage,
+		})
+	}
+}
+
+func (s *server) adminDashboardHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		dashboard, err := s.app.GetDashboard()
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"dashboard": dashboard,
+		})
+	}
+}
+
+func (s *server) adminGrantSolveHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			TeamID      uint32  `json:"team_id"`
+			ChallengeID uint32  `json:"challenge_id"`
+			UserID      *uint32 `json:"user_id"`
+			SolvedAt    int64   `json:"solved_at"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditChallenge(c, req.ChallengeID)
+		chal, err := s.app.GrantSolve(c.User, req.TeamID, req.ChallengeID, req.UserID, req.SolvedAt)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		s.notifySolvesChanged(c, chal)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": GrantSolveMessage,
+		})
+	}
+}
+
+func (s *server) adminRevokeSolveHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			TeamID      uint32 `json:"team_id"`
+			ChallengeID uint32 `json:"challenge_id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditChallenge(c, req.ChallengeID)
+		chal, err := s.app.RevokeSolve(req.TeamID, req.ChallengeID)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		s.notifySolvesChanged(c, chal)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": RevokeSolveMessage,
+		})
+	}
+}
+
+// notifySolvesChanged sends the new solve teams and the score to the clients.
+// closed challenges are not sent because players can not see them
+func (s *server) notifySolvesChanged(c echo.Context, chal *model.Challenge) {
+	if !chal.IsOpen {
+		return
+	}
+	uc, err := s.userChallenge(chal)
+	if err != nil {
+		c.Logger().Error(err)
+		return
+	}
+	if err := s.wsChallengeUpdate(uc); err != nil {
+		c.Logger().Error(err)
+	}
+}
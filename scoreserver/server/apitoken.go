@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const apiTokenScopeKey = "apiTokenScope"
+
+// apiTokenScope allows personal API tokens with the scope on the route.
+// it must be placed before loginMiddleware. routes without it accept only the session cookie
+func apiTokenScope(scope string) echo.MiddlewareFunc {
+	return func(h echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(apiTokenScopeKey, scope)
+			return h(c)
+		}
+	}
+}
+
+// getAPITokenUser authenticates the request with `Authorization: Bearer <token>`.
+// the second value is false when the request has no bearer token, then the session cookie should be used.
+// when scope is empty, the scope allowed by apiTokenScope is required
+func (s *server) getAPITokenUser(c echo.Context, scope string) (*model.User, bool) {
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+
+	if scope == "" {
+		allowed, ok := c.Get(apiTokenScopeKey).(string)
+		if !ok {
+			return nil, true
+		}
+		scope = allowed
+	}
+	user, err := s.app.GetAPITokenUser(token, scope)
+	if err != nil {
+		return nil, true
+	}
+	return user, true
+}
+
+func (s *server) apiTokensHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		tokens, err := s.app.ListAPITokens(c.User)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"tokens": tokens,
+		})
+	}
+}
+
+func (s *server) createAPITokenHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Name      string   `json:"name"`
+			Scopes    []string `json:"scopes"`
+			ExpiresAt *int64   `json:"expires_at"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+
+		session := ""
+		if cookie, err := c.Cookie(sessionKey); err == nil {
+			session = cookie.Value
+		}
+		t, secret, err := s.app.CreateAPIToken(c.User, session, req.Name, req.Scopes, req.ExpiresAt)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"token":  t,
+			"secret": secret,
+		})
+	}
+}
+
+func (s *server) revokeAPITokenHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			ID uint32 `json:"id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.RevokeAPIToken(c.User, req.ID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": APITokenRevokedMessage,
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const AttachmentURLLifetime = 30 * time.Minute
+
+// attachmentSignature binds a challenge attachment to an expiration time
+// so that a proxy link can not be reused after it expires.
+func (s *server) attachmentSignature(cid uint32, name string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "attachment:%d:%s:%d", cid, name, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validAttachmentSignature checks the signature and the expiration time of a proxy link
+func (s *server) validAttachmentSignature(cid uint32, name string, expires int64, sig string) bool {
+	expected := s.attachmentSignature(cid, name, expires)
+	return hmac.Equal([]byte(expected), []byte(sig)) && expires >= time.Now().Unix()
+}
+
+// attachmentName returns the unescaped last element of the attachment URL.
+// the proxy signs and compares only this form of the name
+func attachmentName(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return path.Base(rawurl)
+	}
+	return path.Base(u.Path)
+}
+
+// requestAttachmentName returns the unescaped name in the request path. echo unescapes the path parameter
+// except when the path has escapes which are not the default ones (URL.RawPath is set), like %2F or %3B
+func requestAttachmentName(c echo.Context) (string, error) {
+	name := c.Param("name")
+	if c.Request().URL.RawPath == "" {
+		return name, nil
+	}
+	return url.PathUnescape(name)
+}
+
+func (s *server) attachmentURL(cid uint32, rawurl string) string {
+	name := attachmentName(rawurl)
+	expires := time.Now().Add(AttachmentURLLifetime).Unix()
+
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", s.attachmentSignature(cid, name, expires))
+	return fmt.Sprintf("/attachments/%d/%s?%s", cid, url.PathEscape(name), q.Encode())
+}
+
+// userChallenge converts a challenge for players and replaces the attachment URLs
+// with signed links to the attachment proxy
+func (s *server) userChallenge(chal *model.Challenge) (*model.UserChallengeInfo, error) {
+	uc, err := model.UserChallenge(chal)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := make([]string, 0, len(chal.Attachments))
+	for _, u := range chal.Attachments {
+		attachments = append(attachments, s.attachmentURL(chal.ID, u))
+	}
+	uc.Attachments = attachments
+	return uc, nil
+}
+
+func (s *server) attachmentHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		cid, err := strconv.ParseUint(c.Param("cid"), 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		name, err := requestAttachmentName(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		expires, err := strconv.ParseInt(c.QueryParam("expires"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+
+		if !s.validAttachmentSignature(uint32(cid), name, expires, c.QueryParam("sig")) {
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"message": AttachmentExpiredMessage,
+			})
+		}
+
+		chal, err := s.app.GetChallenge(uint32(cid))
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		if !chal.IsOpen && !c.User.IsAdmin {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"message": AttachmentNotFoundMessage,
+			})
+		}
+
+		rawurl := ""
+		for _, u := range chal.Attachments {
+			if attachmentName(u) == name {
+				rawurl = u
+				break
+			}
+		}
+		if rawurl == "" {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"message": AttachmentNotFoundMessage,
+			})
+		}
+
+		req, err := http.NewRequestWithContext(c.Request().Context(), http.MethodGet, rawurl, nil)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		defer res.Body.Close()
+		if !(200 <= res.StatusCode && res.StatusCode < 300) {
+			return errorHandle(c, fmt.Errorf("failed to fetch attachment %s: %s", rawurl, res.Status))
+		}
+
+		contentType := res.Header.Get(echo.HeaderContentType)
+		if contentType == "" {
+			contentType = echo.MIMEOctetStream
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+		c.Response().Header().Set("Cache-Control", "private, no-store")
+		if res.ContentLength >= 0 {
+			c.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(res.ContentLength, 10))
+		}
+		c.Response().Header().Set(echo.HeaderContentType, contentType)
+		c.Response().WriteHeader(http.StatusOK)
+		_, err = io.Copy(c.Response(), res.Body)
+		return err
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/service"
+)
+
+// challengeApp is an App which knows only one challenge. the other methods are not used by the attachment proxy
+type challengeApp struct {
+	service.App
+	chal *model.Challenge
+}
+
+func (a *challengeApp) GetChallenge(id uint32) (*model.Challenge, error) {
+	if id != a.chal.ID {
+		return nil, service.ErrorMessage("challenge not found")
+	}
+	return a.chal, nil
+}
+
+func TestAttachmentSignature(t *testing.T) {
+	s := &server{secret: []byte("secret")}
+	expires := time.Now().Add(time.Minute).Unix()
+	expired := time.Now().Add(-time.Minute).Unix()
+	sig := s.attachmentSignature(1, "dist.tar.gz", expires)
+
+	cases := []struct {
+		name     string
+		cid      uint32
+		filename string
+		expires  int64
+		sig      string
+		ok       bool
+	}{
+		{"valid", 1, "dist.tar.gz", expires, sig, true},
+		{"tampered cid", 2, "dist.tar.gz", expires, sig, false},
+		{"tampered name", 1, "flag.txt", expires, sig, false},
+		{"tampered expires", 1, "dist.tar.gz", expires + 1, sig, false},
+		{"wrong signature", 1, "dist.tar.gz", expires, strings.Repeat("0", len(sig)), false},
+		{"empty signature", 1, "dist.tar.gz", expires, "", false},
+		{"expired", 1, "dist.tar.gz", expired, s.attachmentSignature(1, "dist.tar.gz", expired), false},
+		{"another secret", 1, "dist.tar.gz", expires, (&server{secret: []byte("other")}).attachmentSignature(1, "dist.tar.gz", expires), false},
+	}
+	for _, tc := range cases {
+		if got := s.validAttachmentSignature(tc.cid, tc.filename, tc.expires, tc.sig); got != tc.ok {
+			t.Errorf("%s: expected %v, but got %v", tc.name, tc.ok, got)
+		}
+	}
+}
+
+// TestAttachmentURL requests the generated links through the handler, so that the name is signed and checked in the same form
+func TestAttachmentURL(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	cases := []struct {
+		rawurl string
+		name   string
+	}{
+		{"/files/dist.tar.gz", "dist.tar.gz"},
+		{"/files/dist%20file.tar.gz", "dist file.tar.gz"},
+		{"/files/100%25.tar.gz", "100%.tar.gz"},
+		{"/files/a%3Bb%2Cc.tar.gz", "a;b,c.tar.gz"},
+		{"/files/%E9%A1%8C.tar.gz", "\u984c.tar.gz"},
+	}
+	for _, tc := range cases {
+		if name := attachmentName(upstream.URL + tc.rawurl); name != tc.name {
+			t.Errorf("%s: expected the name %q, but got %q", tc.rawurl, tc.name, name)
+		}
+
+		chal := &model.Challenge{ID: 1, IsOpen: true, Attachments: []string{upstream.URL + tc.rawurl}}
+		s := &server{app: &challengeApp{chal: chal}, secret: []byte("secret")}
+		e := echo.New()
+		e.GET("/attachments/:cid/:name", s.attachmentHandler(), func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				return next(&LoginContext{c, &model.User{ID: 1}})
+			}
+		})
+		req := httptest.NewRequest(http.MethodGet, s.attachmentURL(1, upstream.URL+tc.rawurl), nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, but got %d: %s", tc.rawurl, rec.Code, rec.Body.String())
+			continue
+		}
+		if !strings.Contains(rec.Header().Get(echo.HeaderContentDisposition), fmt.Sprintf("%q", tc.name)) {
+			t.Errorf("%s: wrong Content-Disposition: %s", tc.rawurl, rec.Header().Get(echo.HeaderContentDisposition))
+		}
+	}
+}
+
+func TestAttachmentHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("distfiles"))
+	}))
+	defer upstream.Close()
+
+	chal := &model.Challenge{ID: 1, Attachments: []string{upstream.URL + "/dist.tar.gz"}}
+	s := &server{app: &challengeApp{chal: chal}, secret: []byte("secret")}
+	expires := time.Now().Add(time.Minute).Unix()
+	expired := time.Now().Add(-time.Minute).Unix()
+
+	cases := []struct {
+		name     string
+		isOpen   bool
+		isAdmin  bool
+		filename string
+		expires  int64
+		sig      string
+		status   int
+	}{
+		{"open", true, false, "dist.tar.gz", expires, "", http.StatusOK},
+		{"closed", false, false, "dist.tar.gz", expires, "", http.StatusNotFound},
+		{"closed for admin", false, true, "dist.tar.gz", expires, "", http.StatusOK},
+		{"not an attachment", true, false, "flag.txt", expires, "", http.StatusNotFound},
+		{"expired", true, false, "dist.tar.gz", expired, "", http.StatusForbidden},
+		{"tampered name", true, false, "flag.txt", expires, s.attachmentSignature(1, "dist.tar.gz", expires), http.StatusForbidden},
+		{"tampered cid", true, false, "dist.tar.gz", expires, s.attachmentSignature(2, "dist.tar.gz", expires), http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		chal.IsOpen = tc.isOpen
+		sig := tc.sig
+		if sig == "" {
+			sig = s.attachmentSignature(1, tc.filename, tc.expires)
+		}
+		q := url.Values{}
+		q.Set("expires", strconv.FormatInt(tc.expires, 10))
+		q.Set("sig", sig)
+
+		user := &model.User{ID: 1, IsAdmin: tc.isAdmin}
+		e := echo.New()
+		e.GET("/attachments/:cid/:name", s.attachmentHandler(), func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				return next(&LoginContext{c, user})
+			}
+		})
+		req := httptest.NewRequest(http.MethodGet, "/attachments/1/"+tc.filename+"?"+q.Encode(), nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != tc.status {
+			t.Errorf("%s: expected status %d, but got %d", tc.name, tc.status, rec.Code)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		body, _ := ioutil.ReadAll(rec.Body)
+		if string(body) != "distfiles" {
+			t.Errorf("%s: wrong body: %q", tc.name, body)
+		}
+		if !strings.Contains(rec.Header().Get(echo.HeaderContentDisposition), "dist.tar.gz") {
+			t.Errorf("%s: wrong Content-Disposition: %s", tc.name, rec.Header().Get(echo.HeaderContentDisposition))
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/service"
+)
+
+const (
+	auditKey = "audit"
+	// auditMaxRequest is the size of the request body kept in the log
+	auditMaxRequest = 16 * 1024
+)
+
+// auditRecord is the object changed by an admin request.
+// load returns the current state of the object, or nil when it does not exist
+type auditRecord struct {
+	target string
+	before interface{}
+	load   func() (interface{}, error)
+}
+
+// auditTarget tells the audit log which object the handler changes. call it before changing the object.
+// load is called now and again after the handler to record the diff, so that a half-done change is recorded too.
+// GET requests are recorded only when the handler calls this. load can be nil for the actions without an object
+func auditTarget(c echo.Context, target string, load func() (interface{}, error)) {
+	r, ok := c.Get(auditKey).(*auditRecord)
+	if !ok {
+		return
+	}
+	r.target = target
+	r.load = load
+	if load != nil {
+		before, err := load()
+		if err != nil {
+			c.Logger().Error(err)
+		}
+		r.before = before
+	}
+}
+
+// audit records every admin request except for GET in the audit log after the handler
+func (s *server) audit(h echo.HandlerFunc) echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := c.Request()
+
+		body := ""
+		if req.Method != http.MethodGet && req.Body != nil {
+			head, err := ioutil.ReadAll(io.LimitReader(req.Body, auditMaxRequest))
+			if err != nil {
+				return errorHandle(c, err)
+			}
+			// the handler reads the whole body again
+			req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(head), req.Body))
+			body = redactRequest(head, req.Header.Get(echo.HeaderContentType))
+		}
+
+		record := new(auditRecord)
+		c.Set(auditKey, record)
+		herr := h(c)
+
+		if req.Method == http.MethodGet && record.target == "" {
+			return herr
+		}
+
+		status := c.Response().Status
+		if herr != nil {
+			status = http.StatusInternalServerError
+			if he, ok := herr.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+		}
+		var after interface{}
+		if record.load != nil {
+			var err error
+			if after, err = record.load(); err != nil {
+				c.Logger().Error(err)
+			}
+		}
+		log := &model.AuditLog{
+			ActorID:   c.User.ID,
+			ActorName: c.User.Username,
+			Method:    req.Method,
+			Path:      c.Path(),
+			IP:        c.RealIP(),
+			Status:    status,
+			Target:    record.target,
+			Request:   body,
+		}
+		if err := s.app.RecordAudit(log, record.before, after); err != nil {
+			c.Logger().Error(err)
+		}
+		return herr
+	}
+}
+
+// placeholders of the request bodies which are not recorded. secrets in them can not be hidden
+const (
+	auditUnparsedBody  = "[body not recorded: not a JSON object or too large]"
+	auditMultipartBody = "[multipart body not recorded]"
+)
+
+// redactRequest hides the values of the secret fields in the JSON or form request body.
+// other bodies are replaced with a placeholder, because the logs can not be deleted
+func redactRequest(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(contentType, echo.MIMEApplicationForm):
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return auditUnparsedBody
+		}
+		for k := range form {
+			if isSecretField(k) {
+				form[k] = []string{"[redacted]"}
+			}
+		}
+		return form.Encode()
+
+	case strings.HasPrefix(contentType, echo.MIMEMultipartForm):
+		return auditMultipartBody
+	}
+
+	// a truncated body is not valid JSON either
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return auditUnparsedBody
+	}
+	data, err := json.Marshal(redactFields(fields))
+	if err != nil {
+		return auditUnparsedBody
+	}
+	return string(data)
+}
+
+func redactFields(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, w := range v {
+			if isSecretField(k) {
+				v[k] = "[redacted]"
+			} else {
+				v[k] = redactFields(w)
+			}
+		}
+	case []interface{}:
+		for i, w := range v {
+			v[i] = redactFields(w)
+		}
+	}
+	return v
+}
+
+// secretFields are the names of the request fields which are not recorded.
+// the names are compared exactly, so that fields like country_code are kept
+var secretFields = map[string]struct{}{
+	"password":                 {},
+	"new_password":             {},
+	"token":                    {},
+	"secret":                   {},
+	"code":                     {}, // TOTP or recovery code
+	"totp_code":                {},
+	"recovery_code":            {},
+	"invite_code":              {},
+	"registration_invite_code": {},
+}
+
+func isSecretField(name string) bool {
+	_, ok := secretFields[strings.ToLower(name)]
+	return ok
+}
+
+// auditConfig records the config. the invite code is recorded as its HMAC to tell only whether it is changed
+func (s *server) auditConfig(c echo.Context) {
+	auditTarget(c, "config", func() (interface{}, error) {
+		conf, err := s.app.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			*model.Config
+			InviteCode string `json:"registration_invite_code"`
+		}{conf, s.auditSecret(conf.RegistrationInviteCode)}, nil
+	})
+}
+
+func (s *server) auditSecret(v string) string {
+	if v == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(v))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// auditChallenges records the status and the score of all the challenges by their names
+func (s *server) auditChallenges(c echo.Context) {
+	auditTarget(c, "challenges", func() (interface{}, error) {
+		chals, err := s.app.ListAllChallenges()
+		if err != nil {
+			return nil, err
+		}
+		status := make(map[string]interface{})
+		for _, chal := range chals {
+			status[chal.Name] = map[string]interface{}{
+				"is_open": chal.IsOpen,
+				"score":   chal.Score,
+			}
+		}
+		return status, nil
+	})
+}
+
+func (s *server) auditChallenge(c echo.Context, cid uint32) {
+	auditTarget(c, fmt.Sprintf("challenge:%d", cid), func() (interface{}, error) {
+		chal, err := s.app.GetChallenge(cid)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return map[string]interface{}{
+			"name":       chal.Name,
+			"is_open":    chal.IsOpen,
+			"score":      chal.Score,
+			"solveteams": chal.SolveTeams,
+		}, nil
+	})
+}
+
+func (s *server) auditUser(c echo.Context, uid uint32) {
+	auditTarget(c, fmt.Sprintf("user:%d", uid), func() (interface{}, error) {
+		user, err := s.app.GetAdminUser(uid)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return user, nil
+	})
+}
+
+func (s *server) auditTeam(c echo.Context, tid uint32) {
+	auditTarget(c, fmt.Sprintf("team:%d", tid), func() (interface{}, error) {
+		team, err := s.app.GetAdminTeam(tid)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return team, nil
+	})
+}
+
+// ignoreNotFound drops the error of the object which does not exist (yet or any more)
+func ignoreNotFound(err error) error {
+	if service.IsErrorMessage(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *server) adminAuditLogsHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		filter := &model.AuditFilter{
+			Path:   c.QueryParam("path"),
+			Target: c.QueryParam("target"),
+		}
+		if v := c.QueryParam("actor_id"); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{
+					"message": InvalidRequestMessage,
+				})
+			}
+			id32 := uint32(id)
+			filter.ActorID = &id32
+		}
+		times := map[string]**int64{
+			"since": &filter.Since,
+			"until": &filter.Until,
+		}
+		for name, p := range times {
+			if v := c.QueryParam(name); v != "" {
+				t, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return c.JSON(http.StatusBadRequest, map[string]interface{}{
+						"message": InvalidRequestMessage,
+					})
+				}
+				*p = &t
+			}
+		}
+		limit := 0
+		if v := c.QueryParam("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{
+					"message": InvalidRequestMessage,
+				})
+			}
+		}
+
+		logs, next, err := s.app.SearchAuditLogs(filter, c.QueryParam("cursor"), limit)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"logs": logs,
+			"next": next,
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRedactRequest(t *testing.T) {
+	var testCases = []struct {
+		body        string
+		contentType string
+		expected    string
+	}{
+		{`{"user_id":1,"reason":"cheating"}`, echo.MIMEApplicationJSON, `{"reason":"cheating","user_id":1}`},
+		{`{"password":"p4ssw0rd","user_id":1}`, echo.MIMEApplicationJSON, `{"password":"[redacted]","user_id":1}`},
+		{`{"registration_invite_code":"invite","ctf_name":"zer0pts"}`, echo.MIMEApplicationJSON, `{"ctf_name":"zer0pts","registration_invite_code":"[redacted]"}`},
+		{`{"code":"123456","country_code":"JPN","new_password":"p4ssw0rd"}`, echo.MIMEApplicationJSON, `{"code":"[redacted]","country_code":"JPN","new_password":"[redacted]"}`},
+		{`{"users":[{"password":"p4ssw0rd"}]}`, echo.MIMEApplicationJSON, `{"users":[{"password":"[redacted]"}]}`},
+		{`{"password":"p4ss`, echo.MIMEApplicationJSON, auditUnparsedBody},
+		{`password=p4ssw0rd`, "", auditUnparsedBody},
+		{`user_id=1&password=p4ssw0rd`, echo.MIMEApplicationForm, `password=%5Bredacted%5D&user_id=1`},
+		{`country_code=JPN&invite_code=invite`, echo.MIMEApplicationForm, `country_code=JPN&invite_code=%5Bredacted%5D`},
+		{`user_id=1&password=p4ssw0rd`, echo.MIMEApplicationForm + "; charset=utf-8", `password=%5Bredacted%5D&user_id=1`},
+		{"--x\r\n", echo.MIMEMultipartForm + "; boundary=x", auditMultipartBody},
+		{``, echo.MIMEApplicationJSON, ``},
+	}
+	for _, c := range testCases {
+		if v := redactRequest([]byte(c.body), c.contentType); v != c.expected {
+			t.Errorf("%s: expected %s, but %s", c.body, c.expected, v)
+		}
+	}
+}
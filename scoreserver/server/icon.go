@@ -0,0 +1,80 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/service"
+)
+
+// readIcon reads the uploaded icon from the multipart form field "icon"
+func readIcon(c echo.Context) ([]byte, error) {
+	fh, err := c.FormFile("icon")
+	if err != nil {
+		return nil, service.ErrorMessage("icon is required")
+	}
+	if fh.Size > service.IconMaxBytes {
+		return nil, service.ErrorMessage("icon is too large. the limit is 1MB")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(io.LimitReader(f, service.IconMaxBytes+1))
+}
+
+func (s *server) setIconHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		data, err := readIcon(c)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		iconPath, err := s.app.SetUserIcon(c.User.ID, data)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":   UpdateIconMessage,
+			"icon_path": iconPath,
+		})
+	}
+}
+
+func (s *server) setTeamIconHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		data, err := readIcon(c)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		iconPath, err := s.app.SetTeamIcon(c.User.TeamID, data)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":   UpdateIconMessage,
+			"icon_path": iconPath,
+		})
+	}
+}
+
+func (s *server) iconHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := s.app.GetIcon(c.Param("name"))
+		if err != nil {
+			if service.IsErrorMessage(err) {
+				return c.NoContent(http.StatusNotFound)
+			}
+			return errorHandle(c, err)
+		}
+		// icon names are derived from the content, so the file never changes
+		c.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+		return c.Blob(http.StatusOK, "image/png", data)
+	}
+}
@@ -0,0 +1,428 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateKey    = "oidc_state"
+	oidcIdentityKey = "oidc_identity"
+	oidcReauthKey   = "oidc_reauth"
+
+	// OIDCStateLifetime is how long the user can stay on the identity provider
+	OIDCStateLifetime = 10 * time.Minute
+	// OIDCIdentityLifetime is how long the user can take to fill the registration form after the first login
+	OIDCIdentityLifetime = 30 * time.Minute
+	// OIDCReauthLifetime is how long the re-authentication with the identity provider is valid for the account deletion
+	OIDCReauthLifetime = 5 * time.Minute
+)
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback of this server. it must be registered to the identity provider
+	RedirectURL string
+	// FrontOrigin is where users are sent back to after the callback
+	FrontOrigin string
+}
+
+type OIDC struct {
+	frontOrigin string
+	oauth2      oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+}
+
+// NewOIDC fetches the discovery document of the issuer, so the identity provider must be up
+func NewOIDC(ctx context.Context, conf OIDCConfig) (*OIDC, error) {
+	provider, err := oidc.NewProvider(ctx, conf.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &OIDC{
+		frontOrigin: conf.FrontOrigin,
+		oauth2: oauth2.Config{
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			RedirectURL:  conf.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: conf.ClientID}),
+	}, nil
+}
+
+type oidcState struct {
+	State string `json:"state"`
+	Nonce string `json:"nonce"`
+	// ReauthUserID is the logged in user who re-authenticates. it is 0 for the login
+	ReauthUserID uint32 `json:"reauth_user_id,omitempty"`
+}
+
+// oidcReauth is the identity verified again by the provider for the logged in user
+type oidcReauth struct {
+	UserID   uint32          `json:"user_id"`
+	Identity *model.Identity `json:"identity"`
+}
+
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// identity exchanges the authorization code and verifies the ID token in the response
+func (o *OIDC) identity(ctx context.Context, code, nonce string) (*model.Identity, error) {
+	token, err := o.oauth2.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("id_token is not in the token response")
+	}
+	idToken, err := o.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if !hmac.Equal([]byte(idToken.Nonce), []byte(nonce)) {
+		return nil, errors.New("nonce mismatch")
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &model.Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+func randomString() string {
+	return uuid.New().String()
+}
+
+func (o *OIDC) frontURL(path string, query url.Values) string {
+	if len(query) == 0 {
+		return fmt.Sprintf("%s/#%s", o.frontOrigin, path)
+	}
+	return fmt.Sprintf("%s/#%s?%s", o.frontOrigin, path, query.Encode())
+}
+
+// signedCookieValue serializes v with an expiration time and signs it,
+// so that the value can be kept in the browser without a server side session.
+// purpose is a part of the signature so a value can not be used as another cookie
+func (s *server) signedCookieValue(purpose string, v interface{}, lifetime time.Duration) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	payload := fmt.Sprintf("%d.%s", time.Now().Add(lifetime).Unix(), base64.RawURLEncoding.EncodeToString(data))
+	return payload + "." + s.cookieSignature(purpose, payload), nil
+}
+
+func (s *server) parseSignedCookieValue(purpose, value string, v interface{}) error {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return errors.New("malformed cookie")
+	}
+	payload, sig := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.cookieSignature(purpose, payload))) {
+		return errors.New("invalid signature")
+	}
+
+	parts := strings.SplitN(payload, ".", 2)
+	if len(parts) != 2 {
+		return errors.New("malformed cookie")
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if expires < time.Now().Unix() {
+		return errors.New("expired")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *server) cookieSignature(purpose, payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "cookie:%s:%s", purpose, payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// oidcCookie is Lax because the callback is a navigation from the identity provider
+func (s *server) oidcCookie(name, value string, lifetime time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/oidc",
+		Expires:  time.Now().Add(lifetime),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (s *server) removeOIDCCookie(name string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/oidc",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (s *server) pendingIdentity(c echo.Context) (*model.Identity, bool) {
+	cookie, err := c.Cookie(oidcIdentityKey)
+	if err != nil || cookie.Value == "" {
+		return nil, false
+	}
+	var identity model.Identity
+	if err := s.parseSignedCookieValue(oidcIdentityKey, cookie.Value, &identity); err != nil {
+		return nil, false
+	}
+	return &identity, true
+}
+
+// reauthCookie is sent only to the account API. it is Strict like the session cookie
+func (s *server) reauthCookie(value string, lifetime time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcReauthKey,
+		Value:    value,
+		Path:     "/account",
+		Expires:  time.Now().Add(lifetime),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+func (s *server) removeReauthCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcReauthKey,
+		Value:    "",
+		Path:     "/account",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// reauthIdentity returns the identity the logged in user has just re-authenticated with
+func (s *server) reauthIdentity(c *LoginContext) *model.Identity {
+	cookie, err := c.Cookie(oidcReauthKey)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	var reauth oidcReauth
+	if err := s.parseSignedCookieValue(oidcReauthKey, cookie.Value, &reauth); err != nil {
+		return nil
+	}
+	if reauth.UserID != c.User.ID {
+		return nil
+	}
+	return reauth.Identity
+}
+
+func (s *server) oidcLoginHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		state := oidcState{
+			State: randomString(),
+			Nonce: randomString(),
+		}
+		value, err := s.signedCookieValue(oidcStateKey, state, OIDCStateLifetime)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		c.SetCookie(s.oidcCookie(oidcStateKey, value, OIDCStateLifetime))
+		return c.Redirect(http.StatusFound, s.oidc.oauth2.AuthCodeURL(state.State, oidc.Nonce(state.Nonce)))
+	}
+}
+
+// oidcReauthHandler asks the identity provider to authenticate the logged in user again,
+// so that the users registered with the provider, who do not know their password, can delete their account
+func (s *server) oidcReauthHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		state := oidcState{
+			State:        randomString(),
+			Nonce:        randomString(),
+			ReauthUserID: c.User.ID,
+		}
+		value, err := s.signedCookieValue(oidcStateKey, state, OIDCStateLifetime)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		c.SetCookie(s.oidcCookie(oidcStateKey, value, OIDCStateLifetime))
+		authURL := s.oidc.oauth2.AuthCodeURL(
+			state.State,
+			oidc.Nonce(state.Nonce),
+			oauth2.SetAuthURLParam("prompt", "login"),
+			oauth2.SetAuthURLParam("max_age", "0"),
+		)
+		return c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// oidcCallbackHandler logs in the linked user, or keeps the identity in a signed cookie
+// and sends the user to the registration form when the identity is new
+func (s *server) oidcCallbackHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		fail := func(message string) error {
+			return c.Redirect(http.StatusFound, s.oidc.frontURL("/login", url.Values{"error": {message}}))
+		}
+
+		if e := c.QueryParam("error"); e != "" {
+			return fail(fmt.Sprintf("%s: %s", OIDCFailedMessage, e))
+		}
+		cookie, err := c.Cookie(oidcStateKey)
+		if err != nil {
+			return fail(OIDCFailedMessage)
+		}
+		c.SetCookie(s.removeOIDCCookie(oidcStateKey))
+		var state oidcState
+		if err := s.parseSignedCookieValue(oidcStateKey, cookie.Value, &state); err != nil {
+			return fail(OIDCFailedMessage)
+		}
+		if !hmac.Equal([]byte(state.State), []byte(c.QueryParam("state"))) {
+			return fail(OIDCFailedMessage)
+		}
+
+		identity, err := s.oidc.identity(c.Request().Context(), c.QueryParam("code"), state.Nonce)
+		if err != nil {
+			c.Logger().Error(err)
+			return fail(OIDCFailedMessage)
+		}
+
+		// the identity is checked against the user's identities when it is used
+		if state.ReauthUserID != 0 {
+			value, err := s.signedCookieValue(oidcReauthKey, oidcReauth{UserID: state.ReauthUserID, Identity: identity}, OIDCReauthLifetime)
+			if err != nil {
+				c.Logger().Error(err)
+				return fail(OIDCFailedMessage)
+			}
+			c.SetCookie(s.reauthCookie(value, OIDCReauthLifetime))
+			return c.Redirect(http.StatusFound, s.oidc.frontURL("/account", url.Values{"reauth": {"1"}}))
+		}
+
+		_, token, err := s.app.LoginWithIdentity(identity, sessionInfo(c))
+		if err != nil && !model.IsNotFound(err) {
+			c.Logger().Error(err)
+			return fail(OIDCFailedMessage)
+		}
+		if err == nil {
+			c.SetCookie(s.tokenCookie(token))
+			return c.Redirect(http.StatusFound, s.oidc.frontURL("/", nil))
+		}
+
+		value, err := s.signedCookieValue(oidcIdentityKey, identity, OIDCIdentityLifetime)
+		if err != nil {
+			c.Logger().Error(err)
+			return fail(OIDCFailedMessage)
+		}
+		c.SetCookie(s.oidcCookie(oidcIdentityKey, value, OIDCIdentityLifetime))
+		return c.Redirect(http.StatusFound, s.oidc.frontURL("/register", url.Values{"oidc": {"1"}}))
+	}
+}
+
+func (s *server) oidcIdentityHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		identity, ok := s.pendingIdentity(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": OIDCIdentityExpiredMessage,
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"email": identity.Email,
+		})
+	}
+}
+
+func (s *server) oidcJoinHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			Token      string `json:"token"`
+			Username   string `json:"username"`
+			InviteCode string `json:"invite_code"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		identity, ok := s.pendingIdentity(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": OIDCIdentityExpiredMessage,
+			})
+		}
+
+		_, token, err := s.app.JoinIdentityToTeam(identity, req.Username, req.Token, req.InviteCode, sessionInfo(c))
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		c.SetCookie(s.removeOIDCCookie(oidcIdentityKey))
+		c.SetCookie(s.tokenCookie(token))
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": RegisteredMessage,
+		})
+	}
+}
+
+func (s *server) oidcCreateHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			Username    string `json:"username"`
+			TeamName    string `json:"teamname"`
+			CountryCode string `json:"country"`
+			InviteCode  string `json:"invite_code"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		identity, ok := s.pendingIdentity(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": OIDCIdentityExpiredMessage,
+			})
+		}
+
+		_, token, err := s.app.RegisterIdentityCreateTeam(identity, req.Username, req.TeamName, req.CountryCode, req.InviteCode, sessionInfo(c))
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		c.SetCookie(s.removeOIDCCookie(oidcIdentityKey))
+		c.SetCookie(s.tokenCookie(token))
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": RegisteredMessage,
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// stubIdP is a minimal OpenID Connect provider which issues an ID token for any code
+type stubIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &stubIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/auth",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		payload, _ := json.Marshal(idp.claims)
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		idToken, _ := jws.CompactSerialize()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+func TestOIDCIdentity(t *testing.T) {
+	idp := newStubIdP(t)
+	defer idp.Close()
+
+	o, err := NewOIDC(context.Background(), OIDCConfig{
+		Issuer:      idp.URL,
+		ClientID:    "scoreserver",
+		RedirectURL: "http://localhost:8000/oidc/callback",
+		FrontOrigin: "http://localhost:8080",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp.claims = map[string]interface{}{
+		"iss":            idp.URL,
+		"sub":            "user1",
+		"aud":            "scoreserver",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          "nonce",
+		"email":          "user1@example.com",
+		"email_verified": true,
+	}
+	identity, err := o.identity(context.Background(), "code", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Issuer != idp.URL || identity.Subject != "user1" || identity.Email != "user1@example.com" || !identity.EmailVerified {
+		t.Errorf("wrong identity: %+v", identity)
+	}
+
+	if _, err := o.identity(context.Background(), "code", "othernonce"); err == nil {
+		t.Error("nonce mismatch should be rejected")
+	}
+
+	idp.claims["aud"] = "otherclient"
+	if _, err := o.identity(context.Background(), "code", "nonce"); err == nil {
+		t.Error("token for another client should be rejected")
+	}
+}
+
+func TestSignedCookieValue(t *testing.T) {
+	s := &server{secret: []byte("secret")}
+	state := oidcState{State: "state", Nonce: "nonce"}
+
+	value, err := s.signedCookieValue(oidcStateKey, state, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got oidcState
+	if err := s.parseSignedCookieValue(oidcStateKey, value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != state {
+		t.Errorf("expected %v, but got %v", state, got)
+	}
+
+	if err := s.parseSignedCookieValue(oidcIdentityKey, value, &got); err == nil {
+		t.Error("cookie for another purpose should be rejected")
+	}
+	if err := s.parseSignedCookieValue(oidcStateKey, "1"+value, &got); err == nil {
+		t.Error("tampered cookie should be rejected")
+	}
+
+	expired, err := s.signedCookieValue(oidcStateKey, state, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.parseSignedCookieValue(oidcStateKey, expired, &got); err == nil {
+		t.Error("expired cookie should be rejected")
+	}
+}
+
+func TestReauthIdentity(t *testing.T) {
+	s := &server{secret: []byte("secret")}
+	identity := &model.Identity{Issuer: "https://idp.example.com", Subject: "subject"}
+	reauth, err := s.signedCookieValue(oidcReauthKey, oidcReauth{UserID: 1, Identity: identity}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.signedCookieValue(oidcReauthKey, oidcReauth{UserID: 1, Identity: identity}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := s.signedCookieValue(oidcIdentityKey, oidcReauth{UserID: 1, Identity: identity}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		userID uint32
+		value  string
+		ok     bool
+	}{
+		{"valid", 1, reauth, true},
+		{"no cookie", 1, "", false},
+		{"another user", 2, reauth, false},
+		{"expired", 1, expired, false},
+		{"another purpose", 1, pending, false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/account/delete", nil)
+		if tc.value != "" {
+			req.AddCookie(s.reauthCookie(tc.value, time.Minute))
+		}
+		c := &LoginContext{echo.New().NewContext(req, httptest.NewRecorder()), &model.User{ID: tc.userID}}
+		got := s.reauthIdentity(c)
+		if (got != nil) != tc.ok {
+			t.Errorf("%s: expected ok = %v, but got %v", tc.name, tc.ok, got)
+		}
+		if got != nil && *got != *identity {
+			t.Errorf("%s: expected %v, but got %v", tc.name, identity, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func exportJSON(c echo.Context, data *model.UserExport) error {
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"user-%d.json\"", data.Profile.ID))
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) exportAccountHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		data, err := s.app.ExportUserData(c.User.ID)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return exportJSON(c, data)
+	}
+}
+
+func (s *server) deleteAccountHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.DeleteAccount(c.User, req.Password, req.Code, s.reauthIdentity(c)); err != nil {
+			return errorHandle(c, err)
+		}
+		c.SetCookie(s.removeReauthCookie())
+		c.SetCookie(s.removeTokenCookie())
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": DeleteAccountMessage,
+		})
+	}
+}
+
+func (s *server) adminExportUserHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		uid, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		// reading the personal data is recorded too
+		auditTarget(c, fmt.Sprintf("user:%d", uid), nil)
+		data, err := s.app.ExportUserData(uint32(uid))
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return exportJSON(c, data)
+	}
+}
+
+func (s *server) adminDeleteUserHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			UserID uint32 `json:"user_id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditUser(c, req.UserID)
+		if err := s.app.AdminDeleteUser(c.User, req.UserID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": DeleteUserMessage,
+		})
+	}
+}
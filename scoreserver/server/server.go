@@ -22,10 +22,36 @@ const (
 	RegisteredMessage             = "registered"
 	LoginMessage                  = "logged in"
 	LogoutMessage                 = "logged out"
-	PasswordResetTokenSentMessage = "password reset token sent to your email"
+	PasswordResetTokenSentMessage = "if the email is registered, a password reset link has been sent to it"
 	PasswordResetMessage          = "your password is updated"
+	UpdateUsernameMessage         = "username updated"
+	UpdatePasswordMessage         = "password updated. other sessions are logged out"
+	EmailConfirmationSentMessage  = "confirmation token sent to your new email"
+	UpdateEmailMessage            = "email updated"
 	UpdateTeamNameMessage         = "teamname updated"
 	UpdateCountryMessage          = "country updated"
+	LeaveTeamMessage              = "you left the team"
+	KickMemberMessage             = "the member is removed from your team"
+	RegenerateTokenMessage        = "team token regenerated"
+	UpdateIconMessage             = "icon updated"
+	EmailVerifiedMessage          = "your email is verified"
+	VerificationSentMessage       = "verification email sent"
+	TOTPEnabledMessage            = "two-factor authentication enabled. keep the recovery codes safe"
+	TOTPDisabledMessage           = "two-factor authentication disabled"
+	APITokenRevokedMessage        = "api token revoked"
+	SessionRevokedMessage         = "session revoked"
+	OtherSessionsRevokedMessage   = "other sessions are logged out"
+	ForceLogoutMessage            = "the user is logged out"
+	UpdateUserMessage             = "user updated"
+	BanUserMessage                = "the user is banned and logged out"
+	UnbanUserMessage              = "the user is unbanned"
+	MoveUserMessage               = "the user is moved to the team"
+	UpdateTeamMessage             = "team updated"
+	DeleteTeamMessage             = "the team and its members are deleted"
+	DeleteAccountMessage          = "your account is deleted"
+	DeleteUserMessage             = "the user is deleted"
+	GrantSolveMessage             = "the solve is granted"
+	RevokeSolveMessage            = "the solve is revoked"
 
 	SubmissionLockMessage = "your team's submission is locked"
 
@@ -37,6 +63,12 @@ const (
 	WrongFlagMessage = "wrong flag"
 	CorrectMessage   = "ALREADY SOLVED: %s"
 	ValidMessage     = "SOLVED: %s"
+
+	AttachmentExpiredMessage  = "the download link is expired. please reload the page"
+	AttachmentNotFoundMessage = "attachment not found"
+
+	OIDCFailedMessage          = "failed to login with the identity provider"
+	OIDCIdentityExpiredMessage = "please login with the identity provider again"
 )
 
 var ()
@@ -48,13 +80,18 @@ type Server interface {
 type server struct {
 	app          service.App
 	allowOrigins []string
+	secret       []byte
+	oidc         *OIDC
 	upgrader     websocket.Upgrader
 }
 
-func New(app service.App, allowOrigins []string) Server {
+// New creates a server. OIDC login is disabled when oidc is nil
+func New(app service.App, allowOrigins []string, secret []byte, oidc *OIDC) Server {
 	return &server{
 		app:          app,
 		allowOrigins: allowOrigins,
+		secret:       secret,
+		oidc:         oidc,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				origin := r.Header.Get("Origin")
@@ -90,26 +127,87 @@ func (s *server) Start(addr string) error {
 	e.POST("/join-team", s.joinHandler())
 	e.POST("/create-team", s.createHandler())
 
+	if s.oidc != nil {
+		e.GET("/oidc/login", s.oidcLoginHandler())
+		e.GET("/oidc/reauth", s.oidcReauthHandler(), s.loginMiddleware)
+		e.GET("/oidc/callback", s.oidcCallbackHandler())
+		e.GET("/oidc/identity", s.oidcIdentityHandler())
+		e.POST("/oidc/join-team", s.oidcJoinHandler())
+		e.POST("/oidc/create-team", s.oidcCreateHandler())
+	}
+
 	e.POST("/reset-request", s.passwordResetRequestHandler())
 	e.POST("/reset", s.passwordResetHandler())
 
-	e.GET("/challenges", s.challengesHandler(), s.loginMiddleware, s.CTFStartedMiddleware)
-	e.POST("/submit", s.submitHandler(), s.loginMiddleware, s.CTFStartedMiddleware)
+	e.POST("/account/username", s.changeUsernameHandler(), s.loginMiddleware)
+	e.POST("/account/password", s.changePasswordHandler(), s.loginMiddleware)
+	e.POST("/account/email", s.changeEmailHandler(), s.loginMiddleware)
+	e.POST("/confirm-email", s.confirmEmailHandler())
+	e.POST("/account/totp/enroll", s.enrollTOTPHandler(), s.loginMiddleware)
+	e.POST("/account/totp/activate", s.activateTOTPHandler(), s.loginMiddleware)
+	e.POST("/account/totp/disable", s.disableTOTPHandler(), s.loginMiddleware)
+	e.GET("/account/sessions", s.sessionsHandler(), s.loginMiddleware)
+	e.POST("/account/sessions/revoke", s.revokeSessionHandler(), s.loginMiddleware)
+	e.POST("/account/sessions/revoke-others", s.revokeOtherSessionsHandler(), s.loginMiddleware)
+	e.GET("/account/api-tokens", s.apiTokensHandler(), s.loginMiddleware)
+	e.POST("/account/api-tokens", s.createAPITokenHandler(), s.loginMiddleware)
+	e.POST("/account/api-tokens/revoke", s.revokeAPITokenHandler(), s.loginMiddleware)
+	e.GET("/account/export", s.exportAccountHandler(), s.loginMiddleware)
+	e.POST("/account/delete", s.deleteAccountHandler(), s.loginMiddleware)
+	e.POST("/verify-email", s.verifyEmailHandler())
+	e.POST("/resend-verification", s.resendVerificationHandler(), s.loginMiddleware)
+
+	e.GET("/challenges", s.challengesHandler(), apiTokenScope(model.ScopeReadChallenges), s.loginMiddleware, s.CTFStartedMiddleware)
+	e.POST("/submit", s.submitHandler(), apiTokenScope(model.ScopeSubmit), s.loginMiddleware, s.CTFStartedMiddleware)
+	e.GET("/attachments/:cid/:name", s.attachmentHandler(), apiTokenScope(model.ScopeReadChallenges), s.loginMiddleware)
+	e.GET("/challenges/:id/statistics", s.challengeStatisticsHandler(), apiTokenScope(model.ScopeReadChallenges), s.loginMiddleware, s.CTFStartedMiddleware)
 
 	e.GET("/team/:id", s.teamPageHandler(), s.loginMiddleware)
 	e.GET("/teams", s.teamsHandler())
 	e.GET("/scorefeed", s.scoreFeedHandler())
 	e.POST("/set-country", s.setCountryHandler(), s.loginMiddleware)
 	e.POST("/set-teamname", s.setTeamNameHandler(), s.loginMiddleware)
-
+	e.POST("/leave-team", s.leaveTeamHandler(), s.loginMiddleware)
+	e.POST("/kick-member", s.kickMemberHandler(), s.loginMiddleware)
+	e.POST("/regenerate-token", s.regenerateTokenHandler(), s.loginMiddleware)
+	e.POST("/set-icon", s.setIconHandler(), s.loginMiddleware)
+	e.POST("/set-team-icon", s.setTeamIconHandler(), s.loginMiddleware)
+	e.GET("/icons/:name", s.iconHandler())
+
+	e.GET("/admin/config", s.adminConfigHandler(), s.adminMiddleware)
 	e.GET("/admin/challenges", s.adminChallengesHandler(), s.adminMiddleware)
+	e.GET("/admin/challenges/:id/statistics", s.adminChallengeStatisticsHandler(), s.adminMiddleware)
 	e.POST("/admin/set-challenges-status", s.adminSetChallengesStatusHandler(), s.adminMiddleware)
 	e.POST("/admin/scoreupdate", s.adminScoreUpdateHandler(), s.adminMiddleware)
 	e.POST("/set-ctf", s.setCTFHandler(), s.adminMiddleware)
+	e.POST("/admin/force-logout", s.adminForceLogoutHandler(), s.adminMiddleware)
+	e.GET("/admin/dashboard", s.adminDashboardHandler(), s.adminMiddleware)
+	e.GET("/admin/submissions", s.adminSubmissionsHandler(), s.adminMiddleware)
+	e.GET("/admin/users", s.adminUsersHandler(), s.adminMiddleware)
+	e.GET("/admin/teams", s.adminTeamsHandler(), s.adminMiddleware)
+	e.POST("/admin/set-user-hidden", s.adminSetUserHiddenHandler(), s.adminMiddleware)
+	e.POST("/admin/ban-user", s.adminBanUserHandler(), s.adminMiddleware)
+	e.POST("/admin/unban-user", s.adminUnbanUserHandler(), s.adminMiddleware)
+	e.POST("/admin/set-user-admin", s.adminSetUserAdminHandler(), s.adminMiddleware)
+	e.POST("/admin/move-user", s.adminMoveUserHandler(), s.adminMiddleware)
+	e.POST("/admin/set-team-hidden", s.adminSetTeamHiddenHandler(), s.adminMiddleware)
+	e.POST("/admin/delete-team", s.adminDeleteTeamHandler(), s.adminMiddleware)
+	e.POST("/admin/grant-solve", s.adminGrantSolveHandler(), s.adminMiddleware)
+	e.POST("/admin/revoke-solve", s.adminRevokeSolveHandler(), s.adminMiddleware)
+	e.GET("/admin/users/:id/export", s.adminExportUserHandler(), s.adminMiddleware)
+	e.POST("/admin/delete-user", s.adminDeleteUserHandler(), s.adminMiddleware)
+	e.GET("/admin/audit-logs", s.adminAuditLogsHandler(), s.adminMiddleware)
 
 	return e.Start(addr)
 }
 
+func sessionInfo(c echo.Context) *model.SessionInfo {
+	return &model.SessionInfo{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
+
 type LoginContext struct {
 	echo.Context
 	User *model.User
@@ -127,7 +225,10 @@ func (s *server) getLoginUser(c echo.Context) *model.User {
 
 func (s *server) loginMiddleware(h echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		user := s.getLoginUser(c)
+		user, isAPIToken := s.getAPITokenUser(c, "")
+		if !isAPIToken {
+			user = s.getLoginUser(c)
+		}
 		if user == nil {
 			return c.JSON(http.StatusUnauthorized, map[string]interface{}{
 				"message": UnauthorizedMessage,
@@ -139,13 +240,33 @@ func (s *server) loginMiddleware(h echo.HandlerFunc) echo.HandlerFunc {
 
 func (s *server) adminMiddleware(h echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		user := s.getLoginUser(c)
+		user, isAPIToken := s.getAPITokenUser(c, model.ScopeAdmin)
+		if isAPIToken {
+			// the second factor was checked when the token was created
+			if user == nil {
+				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+					"message": UnauthorizedMessage,
+				})
+			}
+			return s.audit(h)(&LoginContext{c, user})
+		}
+
+		user = s.getLoginUser(c)
 		if user == nil || !user.IsAdmin {
 			return c.JSON(http.StatusUnauthorized, map[string]interface{}{
 				"message": UnauthorizedMessage,
 			})
 		}
-		return h(&LoginContext{c, user})
+		cookie, _ := c.Cookie(sessionKey)
+		if err := s.app.CheckAdminSession(cookie.Value); err != nil {
+			if service.IsErrorMessage(err) {
+				return c.JSON(http.StatusForbidden, map[string]interface{}{
+					"message": err.Error(),
+				})
+			}
+			return errorHandle(c, err)
+		}
+		return s.audit(h)(&LoginContext{c, user})
 	}
 }
 
@@ -178,12 +299,21 @@ func errorHandle(c echo.Context, err error) error {
 func (s *server) wsHandler() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		user := s.getLoginUser(c)
+		// admin messages are sent only to the sessions which can use the admin APIs
+		mfa := false
+		if user != nil && user.IsAdmin {
+			cookie, _ := c.Cookie(sessionKey)
+			var err error
+			if mfa, err = s.app.IsMFASession(cookie.Value); err != nil {
+				return errorHandle(c, err)
+			}
+		}
 		ws, err := s.upgrader.Upgrade(c.Response(), c.Request(), nil)
 		if err != nil {
 			return errorHandle(c, err)
 		}
 
-		client := s.app.NewClient(ws, user)
+		client := s.app.NewClient(ws, user, mfa)
 		s.app.Add(client)
 		go client.SendHandler()
 		return nil
@@ -198,6 +328,7 @@ func (s *server) ctfHandler() echo.HandlerFunc {
 		}
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"config": conf,
+			"oidc":   s.oidc != nil,
 		})
 	}
 }
@@ -229,7 +360,9 @@ func (s *server) userHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
 		c := cc.(*LoginContext)
 		return c.JSON(http.StatusOK, map[string]interface{}{
-			"username": c.User.Username,
+			"username":       c.User.Username,
+			"email_verified": c.User.EmailVerified,
+			"totp_enabled":   c.User.TOTPEnabled,
 		})
 	}
 }
@@ -239,6 +372,7 @@ func (s *server) loginHandler() echo.HandlerFunc {
 		req := new(struct {
 			Username string `json:"username"`
 			Password string `json:"Password"`
+			Code     string `json:"code"`
 		})
 		if err := c.Bind(req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]interface{}{
@@ -246,7 +380,7 @@ func (s *server) loginHandler() echo.HandlerFunc {
 			})
 		}
 
-		_, token, err := s.app.LoginUser(req.Username, req.Password)
+		_, token, err := s.app.LoginUser(req.Username, req.Password, req.Code, sessionInfo(c))
 		if err != nil {
 			return errorHandle(c, err)
 		}
@@ -261,9 +395,12 @@ func (s *server) loginHandler() echo.HandlerFunc {
 func (s *server) logoutHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
 		c := cc.(*LoginContext)
-		err := s.app.LogoutUser(c.User.ID)
-		if err != nil {
-			return errorHandle(c, err)
+		// only this session. the other sessions are revoked by /account/sessions/revoke-others
+		cookie, err := c.Cookie(sessionKey)
+		if err == nil {
+			if err := s.app.LogoutUserByToken(cookie.Value); err != nil {
+				return errorHandle(c, err)
+			}
 		}
 		c.SetCookie(s.removeTokenCookie())
 		return c.JSON(http.StatusOK, map[string]interface{}{
@@ -275,17 +412,18 @@ func (s *server) logoutHandler() echo.HandlerFunc {
 func (s *server) joinHandler() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		req := new(struct {
-			Token    string `json:"token"`
-			Username string `json:"username"`
-			Email    string `json:"email"`
-			Password string `json:"password"`
+			Token      string `json:"token"`
+			Username   string `json:"username"`
+			Email      string `json:"email"`
+			Password   string `json:"password"`
+			InviteCode string `json:"invite_code"`
 		})
 		if err := c.Bind(req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]interface{}{
 				"message": InvalidRequestMessage,
 			})
 		}
-		err := s.app.JoinUserToTeam(req.Username, req.Email, req.Password, req.Token)
+		err := s.app.JoinUserToTeam(req.Username, req.Email, req.Password, req.Token, req.InviteCode)
 		if err != nil {
 			return errorHandle(c, err)
 		}
@@ -301,16 +439,17 @@ func (s *server) createHandler() echo.HandlerFunc {
 			TeamName    string `json:"teamname"`
 			CountryCode string `json:"country"`
 
-			Username string `json:"username"`
-			Email    string `json:"email"`
-			Password string `json:"password"`
+			Username   string `json:"username"`
+			Email      string `json:"email"`
+			Password   string `json:"password"`
+			InviteCode string `json:"invite_code"`
 		})
 		if err := c.Bind(req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]interface{}{
 				"message": InvalidRequestMessage,
 			})
 		}
-		err := s.app.RegisterUserCreateTeam(req.Username, req.Email, req.Password, req.TeamName, req.CountryCode)
+		err := s.app.RegisterUserCreateTeam(req.Username, req.Email, req.Password, req.TeamName, req.CountryCode, req.InviteCode)
 		if err != nil {
 			return errorHandle(c, err)
 		}
@@ -331,7 +470,7 @@ func (s *server) passwordResetRequestHandler() echo.HandlerFunc {
 			})
 		}
 
-		err := s.app.IssuePasswordResetToken(req.Email)
+		err := s.app.IssuePasswordResetToken(req.Email, sessionInfo(c))
 		if err != nil {
 			return errorHandle(c, err)
 		}
@@ -365,6 +504,187 @@ func (s *server) passwordResetHandler() echo.HandlerFunc {
 	}
 }
 
+func (s *server) changeUsernameHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Password string `json:"password"`
+			Username string `json:"username"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.ChangeUsername(c.User, req.Password, req.Username); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": UpdateUsernameMessage,
+		})
+	}
+}
+
+func (s *server) changePasswordHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Password    string `json:"password"`
+			NewPassword string `json:"new_password"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		cookie, err := c.Cookie(sessionKey)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		if err := s.app.ChangePassword(c.User, cookie.Value, req.Password, req.NewPassword); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": UpdatePasswordMessage,
+		})
+	}
+}
+
+func (s *server) changeEmailHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Password string `json:"password"`
+			Email    string `json:"email"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.RequestEmailChange(c.User, req.Password, req.Email); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": EmailConfirmationSentMessage,
+		})
+	}
+}
+
+func (s *server) confirmEmailHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			Token string `json:"token"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.ConfirmEmailChange(req.Token); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": UpdateEmailMessage,
+		})
+	}
+}
+
+func (s *server) enrollTOTPHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Password string `json:"password"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		uri, err := s.app.EnrollTOTP(c.User, req.Password)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"uri": uri,
+		})
+	}
+}
+
+func (s *server) activateTOTPHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Code string `json:"code"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		codes, err := s.app.ActivateTOTP(c.User, req.Code)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":        TOTPEnabledMessage,
+			"recovery_codes": codes,
+		})
+	}
+}
+
+func (s *server) disableTOTPHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.DisableTOTP(c.User, req.Password, req.Code); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": TOTPDisabledMessage,
+		})
+	}
+}
+
+func (s *server) verifyEmailHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			Token string `json:"token"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.VerifyEmail(req.Token); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": EmailVerifiedMessage,
+		})
+	}
+}
+
+func (s *server) resendVerificationHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		if err := s.app.ResendVerificationEmail(c.User); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": VerificationSentMessage,
+		})
+	}
+}
+
 func (s *server) challengesHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
 		c := cc.(*LoginContext)
@@ -375,7 +695,7 @@ func (s *server) challengesHandler() echo.HandlerFunc {
 		}
 		userchals := make([]*model.UserChallengeInfo, 0, len(chals))
 		for _, chal := range chals {
-			uc, err := model.UserChallenge(chal)
+			uc, err := s.userChallenge(chal)
 			if err != nil {
 				c.Logger().Error(err)
 				continue
@@ -389,6 +709,34 @@ func (s *server) challengesHandler() echo.HandlerFunc {
 	}
 }
 
+func (s *server) challengeStatisticsHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		cid, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+
+		chal, err := s.app.GetChallenge(uint32(cid))
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		if !chal.IsOpen && !c.User.IsAdmin {
+			return errorHandle(c, service.ErrorMessage("challenge not found"))
+		}
+
+		stats, err := s.app.GetChallengeStatistics(chal.ID)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"statistics": model.PublicStatistics(stats),
+		})
+	}
+}
+
 func (s *server) submitHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
 		c := cc.(*LoginContext)
@@ -433,18 +781,30 @@ func (s *server) submitHandler() echo.HandlerFunc {
 			if err != nil {
 				return errorHandle(c, err)
 			}
-			if cnt >= conf.LockCount {
+			locked := cnt >= conf.LockCount
+			if locked {
 				err = s.app.LockSubmission(t.ID, time.Duration(conf.LockDuration)*time.Second)
 				if err != nil {
 					return errorHandle(c, err)
 				}
 			}
+			if err := s.wsAdminSubmission(c.User, t, nil, SubmissionWrong, locked); err != nil {
+				c.Logger().Error(err)
+			}
 
 			return c.JSON(http.StatusOK, map[string]interface{}{
 				"message": WrongFlagMessage,
 			})
 		}
 
+		result := SubmissionDuplicate
+		if valid {
+			result = SubmissionCorrect
+		}
+		if err := s.wsAdminSubmission(c.User, t, chal, result, false); err != nil {
+			c.Logger().Error(err)
+		}
+
 		// if correct/valid flag
 		if valid {
 			if chal.IsDynamic {
@@ -460,7 +820,7 @@ func (s *server) submitHandler() echo.HandlerFunc {
 					c.Logger().Error(err)
 					break
 				}
-				userchal, err := model.UserChallenge(chal)
+				userchal, err := s.userChallenge(chal)
 				if err != nil {
 					c.Logger().Error(err)
 					break
@@ -498,8 +858,25 @@ func (s *server) teamPageHandler() echo.HandlerFunc {
 		if t.ID != c.User.TeamID {
 			t.Token = ""
 		}
+
+		conf, err := s.app.GetConfig()
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		// remaining_slots is null when the team size is unlimited
+		var remaining *int
+		if conf.MaxTeamSize > 0 {
+			r := conf.MaxTeamSize - len(t.Users)
+			if r < 0 {
+				r = 0
+			}
+			remaining = &r
+		}
 		return c.JSON(http.StatusOK, map[string]interface{}{
-			"team": t,
+			"team":            t,
+			"max_team_size":   conf.MaxTeamSize,
+			"remaining_slots": remaining,
+			"solo_mode":       conf.SoloMode,
 		})
 	}
 }
@@ -630,6 +1007,54 @@ func (s *server) setCountryHandler() echo.HandlerFunc {
 	}
 }
 
+func (s *server) leaveTeamHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		team, err := s.app.LeaveTeam(c.User)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": LeaveTeamMessage,
+			"team":    team,
+		})
+	}
+}
+
+func (s *server) kickMemberHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			UserID uint32 `json:"user_id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.KickMember(c.User, req.UserID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": KickMemberMessage,
+		})
+	}
+}
+
+func (s *server) regenerateTokenHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		token, err := s.app.RegenerateTeamToken(c.User)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": RegenerateTokenMessage,
+			"token":   token,
+		})
+	}
+}
+
 func (s *server) setCTFHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
 		req := new(struct {
@@ -642,12 +1067,24 @@ func (s *server) setCTFHandler() echo.HandlerFunc {
 			EasySolves   int    `json:"easy_solves"`
 			MediumSolves int    `json:"medium_solves"`
 			MinScore     int    `json:"min_score"`
+			MaxTeamSize  int    `json:"max_team_size"`
+
+			EmailVerification bool `json:"email_verification"`
+			RequireAdminTOTP  bool `json:"require_admin_totp"`
+			SoloMode          bool `json:"solo_mode"`
+
+			RegistrationPolicy     string `json:"registration_policy"`
+			RegistrationInviteCode string `json:"registration_invite_code"`
+			RegistrationDomains    string `json:"registration_domains"`
+			RegistrationStart      *int64 `json:"registration_start"`
+			RegistrationEnd        *int64 `json:"registration_end"`
 		})
 		if err := cc.Bind(req); err != nil {
 			return cc.JSON(http.StatusBadRequest, map[string]interface{}{
 				"message": InvalidRequestMessage,
 			})
 		}
+		s.auditConfig(cc)
 
 		if err := s.app.SetCTFName(req.CTFName); err != nil {
 			return errorHandle(cc, err)
@@ -667,6 +1104,21 @@ func (s *server) setCTFHandler() echo.HandlerFunc {
 		if err := s.app.SetMinScore(req.MinScore); err != nil {
 			return errorHandle(cc, err)
 		}
+		if err := s.app.SetMaxTeamSize(req.MaxTeamSize); err != nil {
+			return errorHandle(cc, err)
+		}
+		if err := s.app.SetEmailVerification(req.EmailVerification); err != nil {
+			return errorHandle(cc, err)
+		}
+		if err := s.app.SetRequireAdminTOTP(req.RequireAdminTOTP); err != nil {
+			return errorHandle(cc, err)
+		}
+		if err := s.app.SetSoloMode(req.SoloMode); err != nil {
+			return errorHandle(cc, err)
+		}
+		if err := s.app.SetRegistration(req.RegistrationPolicy, req.RegistrationInviteCode, req.RegistrationDomains, req.RegistrationStart, req.RegistrationEnd); err != nil {
+			return errorHandle(cc, err)
+		}
 
 		return cc.JSON(http.StatusOK, map[string]interface{}{
 			"message": ConfigUpdateMessage,
@@ -674,6 +1126,20 @@ func (s *server) setCTFHandler() echo.HandlerFunc {
 	}
 }
 
+// adminConfigHandler returns the config with the secret fields which /ctf does not show
+func (s *server) adminConfigHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		conf, err := s.app.GetConfig()
+		if err != nil {
+			return errorHandle(cc, err)
+		}
+		return cc.JSON(http.StatusOK, map[string]interface{}{
+			"config":                   conf,
+			"registration_invite_code": conf.RegistrationInviteCode,
+		})
+	}
+}
+
 func (s *server) adminChallengesHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
 		chals, err := s.app.ListAllChallenges()
@@ -686,6 +1152,24 @@ func (s *server) adminChallengesHandler() echo.HandlerFunc {
 	}
 }
 
+func (s *server) adminChallengeStatisticsHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		cid, err := strconv.ParseUint(cc.Param("id"), 10, 32)
+		if err != nil {
+			return cc.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		stats, err := s.app.GetChallengeStatistics(uint32(cid))
+		if err != nil {
+			return errorHandle(cc, err)
+		}
+		return cc.JSON(http.StatusOK, map[string]interface{}{
+			"statistics": stats,
+		})
+	}
+}
+
 func (s *server) adminSetChallengesStatusHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
 		req := new(struct {
@@ -699,6 +1183,7 @@ func (s *server) adminSetChallengesStatusHandler() echo.HandlerFunc {
 				"message": InvalidRequestMessage,
 			})
 		}
+		s.auditChallenges(cc)
 		chals, err := s.app.ListAllChallenges()
 		if err != nil {
 			return errorHandle(cc, err)
@@ -721,7 +1206,7 @@ func (s *server) adminSetChallengesStatusHandler() echo.HandlerFunc {
 			if c.IsOpen {
 				if err := s.app.OpenChallenge(c.ID); err == nil {
 					s.wsMessage(fmt.Sprintf(ChallengeOpenMessage, chals[i].Name))
-					uchal, err := model.UserChallenge(chals[i])
+					uchal, err := s.userChallenge(chals[i])
 					if err == nil {
 						s.wsChallengeUpdate(uchal)
 					} else {
@@ -745,25 +1230,10 @@ func (s *server) adminSetChallengesStatusHandler() echo.HandlerFunc {
 
 func (s *server) adminScoreUpdateHandler() echo.HandlerFunc {
 	return func(cc echo.Context) error {
-		chals, err := s.app.ListOpenChallenges()
-		if err != nil {
+		s.auditChallenges(cc)
+		if err := s.app.RecalcAllScores(); err != nil {
 			return errorHandle(cc, err)
 		}
-
-		conf, err := s.app.GetConfig()
-		if err != nil {
-			return errorHandle(cc, err)
-		}
-
-		for _, chal := range chals {
-			if !chal.IsDynamic {
-				continue
-			}
-			err = s.app.RecalcScore(conf.MinScore, chal.BaseScore, conf.EasySolves, conf.MediumSolves, chal.ID)
-			if err != nil {
-				return errorHandle(cc, err)
-			}
-		}
 		return cc.NoContent(http.StatusOK)
 	}
 }
@@ -774,6 +1244,7 @@ func (s *server) tokenCookie(token string) *http.Cookie {
 	return &http.Cookie{
 		Name:     sessionKey,
 		Value:    token,
+		Path:     "/",
 		Expires:  time.Now().Add(service.TokenLimit),
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
@@ -783,6 +1254,7 @@ func (s *server) removeTokenCookie() *http.Cookie {
 	return &http.Cookie{
 		Name:     sessionKey,
 		Value:    "",
+		Path:     "/",
 		Expires:  time.Time{},
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
@@ -822,6 +1294,55 @@ func (s *server) wsChallengeClose(cid uint32) error {
 
 }
 
+// results of submissions in the admin event
+const (
+	SubmissionCorrect   = "correct"
+	SubmissionWrong     = "wrong"
+	SubmissionDuplicate = "duplicate"
+)
+
+// wsAdminSubmission notifies admins of every submission for the dashboard
+func (s *server) wsAdminSubmission(user *model.User, team *model.Team, chal *model.Challenge, result string, locked bool) error {
+	type submission struct {
+		Result        string  `json:"result"`
+		UserID        uint32  `json:"user_id"`
+		Username      string  `json:"username"`
+		TeamID        uint32  `json:"team_id"`
+		Teamname      string  `json:"teamname"`
+		ChallengeID   *uint32 `json:"challenge_id"`
+		ChallengeName *string `json:"challenge_name"`
+		SubmittedAt   int64   `json:"submitted_at"`
+		Locked        bool    `json:"locked"`
+	}
+	v := submission{
+		Result:      result,
+		UserID:      user.ID,
+		Username:    user.Username,
+		TeamID:      team.ID,
+		Teamname:    team.Teamname,
+		SubmittedAt: time.Now().Unix(),
+		Locked:      locked,
+	}
+	if chal != nil {
+		v.ChallengeID = &chal.ID
+		v.ChallengeName = &chal.Name
+	}
+
+	data, err := json.Marshal(struct {
+		Type       string     `json:"type"`
+		Submission submission `json:"value"`
+	}{
+		Type:       "adminSubmission",
+		Submission: v,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.app.Send(data, true, true)
+	return nil
+}
+
 func (s *server) wsChallengeUpdate(chal *model.UserChallengeInfo) error {
 	data, err := json.Marshal(struct {
 		Type      string                  `json:"type"`
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (s *server) sessionsHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		current := ""
+		if cookie, err := c.Cookie(sessionKey); err == nil {
+			current = cookie.Value
+		}
+		sessions, err := s.app.ListSessions(c.User, current)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"sessions": sessions,
+		})
+	}
+}
+
+func (s *server) revokeSessionHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		req := new(struct {
+			ID uint32 `json:"id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.RevokeSession(c.User, req.ID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": SessionRevokedMessage,
+		})
+	}
+}
+
+func (s *server) revokeOtherSessionsHandler() echo.HandlerFunc {
+	return func(cc echo.Context) error {
+		c := cc.(*LoginContext)
+		cookie, err := c.Cookie(sessionKey)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		if err := s.app.RevokeOtherSessions(c.User, cookie.Value); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": OtherSessionsRevokedMessage,
+		})
+	}
+}
+
+func (s *server) adminForceLogoutHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(struct {
+			UserID uint32 `json:"user_id"`
+		})
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+		s.auditUser(c, req.UserID)
+		if err := s.app.ForceLogout(req.UserID); err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": ForceLogoutMessage,
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+// submissionFilter reads the filter from the query parameters. empty parameters are ignored
+func submissionFilter(c echo.Context) (*model.SubmissionFilter, error) {
+	filter := new(model.SubmissionFilter)
+	ids := map[string]**uint32{
+		"team_id":      &filter.TeamID,
+		"user_id":      &filter.UserID,
+		"challenge_id": &filter.ChallengeID,
+	}
+	for name, p := range ids {
+		if v := c.QueryParam(name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			id32 := uint32(id)
+			*p = &id32
+		}
+	}
+	bools := map[string]**bool{
+		"correct": &filter.IsCorrect,
+		"valid":   &filter.IsValid,
+	}
+	for name, p := range bools {
+		if v := c.QueryParam(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, err
+			}
+			*p = &b
+		}
+	}
+	times := map[string]**int64{
+		"since": &filter.Since,
+		"until": &filter.Until,
+	}
+	for name, p := range times {
+		if v := c.QueryParam(name); v != "" {
+			t, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			*p = &t
+		}
+	}
+	return filter, nil
+}
+
+func (s *server) adminSubmissionsHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		filter, err := submissionFilter(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": InvalidRequestMessage,
+			})
+		}
+
+		if c.QueryParam("format") == "csv" {
+			logs, err := s.app.ExportSubmissions(filter)
+			if err != nil {
+				return errorHandle(c, err)
+			}
+			return submissionsCSV(c, logs)
+		}
+
+		limit := 0
+		if v := c.QueryParam("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{
+					"message": InvalidRequestMessage,
+				})
+			}
+		}
+		logs, next, err := s.app.SearchSubmissions(filter, c.QueryParam("cursor"), limit)
+		if err != nil {
+			return errorHandle(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"submissions": logs,
+			"next":        next,
+		})
+	}
+}
+
+func submissionsCSV(c echo.Context, logs []*model.SubmissionLog) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"submissions-%d.csv\"", time.Now().Unix()))
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	w.Write([]string{"id", "submitted_at", "team_id", "teamname", "user_id", "username", "challenge_id", "challenge_name", "flag", "correct", "valid"})
+	for _, l := range logs {
+		w.Write([]string{
+			strconv.FormatUint(uint64(l.ID), 10),
+			time.Unix(l.SubmittedAt, 0).UTC().Format(time.RFC3339),
+			csvID(l.TeamID),
+			csvCell(l.Teamname),
+			csvID(l.UserID),
+			csvCell(l.Username),
+			csvID(l.ChallengeID),
+			csvCell(l.ChallengeName),
+			csvCell(&l.Flag),
+			strconv.FormatBool(l.IsCorrect),
+			strconv.FormatBool(l.IsValid),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func csvID(id *uint32) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// csvCell escapes the values starting with the formula characters of spreadsheets,
+// because flags, usernames and teamnames are written by the players
+func csvCell(s *string) string {
+	if s == nil {
+		return ""
+	}
+	if *s != "" && strings.ContainsRune("=+-@\t\r", rune((*s)[0])) {
+		return "'" + *s
+	}
+	return *s
+}
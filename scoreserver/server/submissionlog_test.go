@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestCSVCell(t *testing.T) {
+	var testCases = []struct {
+		value    string
+		expected string
+	}{
+		{"zer0pts{flag}", "zer0pts{flag}"},
+		{"", ""},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+	}
+	for _, c := range testCases {
+		if v := csvCell(&c.value); v != c.expected {
+			t.Errorf("%q: expected %q, but %q", c.value, c.expected, v)
+		}
+	}
+	if v := csvCell(nil); v != "" {
+		t.Errorf("nil should be empty, but %q", v)
+	}
+}
+
+func TestSubmissionFilter(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest("GET", "/admin/submissions?team_id=1&correct=true&since=100&user_id=", nil)
+	filter, err := submissionFilter(e.NewContext(req, httptest.NewRecorder()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.TeamID == nil || *filter.TeamID != 1 {
+		t.Errorf("wrong team_id: %v", filter.TeamID)
+	}
+	if filter.IsCorrect == nil || !*filter.IsCorrect {
+		t.Errorf("wrong correct: %v", filter.IsCorrect)
+	}
+	if filter.Since == nil || *filter.Since != 100 {
+		t.Errorf("wrong since: %v", filter.Since)
+	}
+	if filter.UserID != nil || filter.IsValid != nil || filter.Until != nil {
+		t.Errorf("empty parameters should be nil: %+v", filter)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/submissions?challenge_id=abc", nil)
+	if _, err := submissionFilter(e.NewContext(req, httptest.NewRecorder())); err == nil {
+		t.Error("invalid id should be an error")
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const EmailChangeTokenLimit = time.Hour * 1
+
+type AccountApp interface {
+	ChangeUsername(user *model.User, password, username string) error
+	ChangePassword(user *model.User, token, password, newPassword string) error
+	RequestEmailChange(user *model.User, password, email string) error
+	ConfirmEmailChange(token string) error
+}
+
+func (app *app) ChangeUsername(user *model.User, password, username string) error {
+	if !checkPassword(user, password) {
+		return ErrorMessage("wrong password")
+	}
+	if username == "" {
+		return ErrorMessage("username is required")
+	}
+	if username == user.Username {
+		return nil
+	}
+	if err := app.checkUsernameAvailable(username); err != nil {
+		return err
+	}
+	conf, err := app.GetConfig()
+	if err != nil {
+		return err
+	}
+	teamName := ""
+	if conf.SoloMode {
+		teamName, err = app.checkSoloTeamAvailable(username)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = app.repo.UpdateUserName(user.ID, username)
+	if err != nil {
+		if model.IsDuplicated(err) {
+			return ErrorMessage("username already used")
+		}
+		return err
+	}
+	// the team of a solo user follows the username
+	if conf.SoloMode {
+		if err := app.repo.UpdateTeamName(user.TeamID, teamName, teamNameSkeleton(teamName)); err != nil {
+			if model.IsDuplicated(err) {
+				return ErrorMessage("username already used")
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangePassword updates the password and revokes every session of the user except the current one
+func (app *app) ChangePassword(user *model.User, token, password, newPassword string) error {
+	if !checkPassword(user, password) {
+		return ErrorMessage("wrong password")
+	}
+	if newPassword == "" {
+		return ErrorMessage("password is required")
+	}
+
+	passwordHash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := app.repo.UpdateUserPassword(user.ID, passwordHash); err != nil {
+		return err
+	}
+	if err := app.repo.RevokeTokenByUserIDExcept(user.ID, token); err != nil {
+		return err
+	}
+	if err := app.repo.RevokePasswordResetTokenByUserID(user.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RequestEmailChange sends a confirmation token to the new address.
+// the email is not changed until the token is confirmed by ConfirmEmailChange
+func (app *app) RequestEmailChange(user *model.User, password, email string) error {
+	if !checkPassword(user, password) {
+		return ErrorMessage("wrong password")
+	}
+	if email == "" {
+		return ErrorMessage("email is required")
+	}
+	if err := app.checkEmailAvailable(email); err != nil {
+		return err
+	}
+
+	if err := app.repo.RevokeEmailChangeTokenByUserID(user.ID); err != nil {
+		return err
+	}
+	token := app.newToken()
+	err := app.repo.NewEmailChangeToken(user.ID, email, token, uint64(time.Now().Add(EmailChangeTokenLimit).Unix()))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := app.mailer.Send(email, "email confirmation token", fmt.Sprintf("your email confirmation token is: %s", token))
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+	return nil
+}
+
+func (app *app) ConfirmEmailChange(token string) error {
+	t, err := app.repo.FindEmailChangeToken(token)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("invalid token")
+		}
+		return err
+	}
+
+	err = app.repo.UpdateUserEmail(t.UserID, t.Email)
+	if err != nil {
+		if model.IsDuplicated(err) {
+			return ErrorMessage("email already used")
+		}
+		return err
+	}
+	// the token reached the new address, so it is verified too
+	if err := app.repo.SetEmailVerified(t.UserID, true); err != nil {
+		return err
+	}
+	return app.repo.RevokeEmailChangeTokenByUserID(t.UserID)
+}
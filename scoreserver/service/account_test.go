@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestChangePassword(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testchangepassword", "testchangepassword@example.com", "password", "team-testchangepassword", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, current, err := app.LoginUser("testchangepassword", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := app.LoginUser("testchangepassword", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.ChangePassword(user, current, "wrongpassword", "newpassword"); err == nil {
+		t.Error("password changed without the current password")
+	}
+	if err := app.ChangePassword(user, current, "password", "newpassword"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.GetLoginUser(current); err != nil {
+		t.Error("current session should be kept")
+	}
+	if _, err := app.GetLoginUser(other); err == nil {
+		t.Error("other session should be revoked")
+	}
+	if _, _, err := app.LoginUser("testchangepassword", "newpassword", "", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChangeUsername(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testchangeusername", "testchangeusername@example.com", "password", "team-testchangeusername", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testchangeusername", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		password string
+		username string
+		hasError bool
+	}{
+		{"wrongpassword", "testchangeusername2", true},
+		{"password", "", true},
+		{"password", "testchange username", true},
+		{"password", "testchangeusername2", false},
+	}
+	for _, c := range testCases {
+		err := app.ChangeUsername(user, c.password, c.username)
+		if c.hasError != (err != nil) {
+			t.Errorf("case %v, err: %v", c, err)
+		}
+	}
+}
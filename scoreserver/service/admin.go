@@ -0,0 +1,259 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const BanReasonMaxLength = 255
+
+type AdminApp interface {
+	SearchUsers(query string) ([]*model.AdminUser, error)
+	SearchTeams(query string) ([]*model.AdminTeam, error)
+	GetAdminUser(uid uint32) (*model.AdminUser, error)
+	GetAdminTeam(tid uint32) (*model.AdminTeam, error)
+
+	SetUserHidden(uid uint32, hidden bool) error
+	BanUser(actor *model.User, uid uint32, reason string) error
+	UnbanUser(uid uint32) error
+	SetUserAdmin(actor *model.User, uid uint32, admin bool) error
+	MoveUser(actor *model.User, uid, tid uint32) error
+
+	SetTeamHidden(tid uint32, hidden bool) error
+	DeleteTeam(actor *model.User, tid uint32) error
+
+	GrantSolve(actor *model.User, tid, cid uint32, uid *uint32, solvedAt int64) (*model.Challenge, error)
+	RevokeSolve(tid, cid uint32) (*model.Challenge, error)
+}
+
+func (app *app) SearchUsers(query string) ([]*model.AdminUser, error) {
+	return app.repo.ListAdminUsers(query)
+}
+
+func (app *app) SearchTeams(query string) ([]*model.AdminTeam, error) {
+	return app.repo.ListAdminTeams(query)
+}
+
+func (app *app) GetAdminUser(uid uint32) (*model.AdminUser, error) {
+	user, err := app.repo.FindUserProfile(uid)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (app *app) GetAdminTeam(tid uint32) (*model.AdminTeam, error) {
+	team, err := app.repo.FindAdminTeam(tid)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("team not found")
+		}
+		return nil, err
+	}
+	return team, nil
+}
+
+// SetUserHidden hides the user. the solves of a hidden user do not become valid, but the past ones are kept
+func (app *app) SetUserHidden(uid uint32, hidden bool) error {
+	if _, err := app.findUser(uid); err != nil {
+		return err
+	}
+	return app.repo.SetUserHidden(uid, hidden)
+}
+
+// BanUser blocks login and submissions of the user, and logs out every session
+func (app *app) BanUser(actor *model.User, uid uint32, reason string) error {
+	if uid == actor.ID {
+		return ErrorMessage("you can not ban yourself")
+	}
+	if reason == "" {
+		return ErrorMessage("reason is required")
+	}
+	if len(reason) > BanReasonMaxLength {
+		return ErrorMessage(fmt.Sprintf("reason must be at most %d bytes", BanReasonMaxLength))
+	}
+	if _, err := app.findUser(uid); err != nil {
+		return err
+	}
+	if err := app.repo.SetBanReason(uid, &reason); err != nil {
+		return err
+	}
+	return app.repo.RevokeTokenByUserID(uid)
+}
+
+func (app *app) UnbanUser(uid uint32) error {
+	if _, err := app.findUser(uid); err != nil {
+		return err
+	}
+	return app.repo.SetBanReason(uid, nil)
+}
+
+func (app *app) SetUserAdmin(actor *model.User, uid uint32, admin bool) error {
+	if uid == actor.ID && !admin {
+		return ErrorMessage("you can not demote yourself")
+	}
+	if _, err := app.findUser(uid); err != nil {
+		return err
+	}
+	return app.repo.SetUserAdmin(uid, admin)
+}
+
+// MoveUser moves the user to another team. the team size limit is not applied.
+// valid submissions stay in the old team as LeaveTeam does, so the scoreboard does not change
+func (app *app) MoveUser(actor *model.User, uid, tid uint32) error {
+	if err := app.rejectSoloMode(); err != nil {
+		return err
+	}
+	user, err := app.findUser(uid)
+	if err != nil {
+		return err
+	}
+	if user.TeamID == tid {
+		return ErrorMessage("the user is already a member of the team")
+	}
+	oldTeam, err := app.GetTeam(user.TeamID)
+	if err != nil {
+		return err
+	}
+	newTeam, err := app.GetTeam(tid)
+	if err != nil {
+		return err
+	}
+
+	if err := app.repo.MoveToTeam(user.ID, oldTeam.ID, newTeam.ID, &actor.ID); err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("the user is not a member of the team")
+		}
+		return err
+	}
+	return nil
+}
+
+// SetTeamHidden hides the team from the scoreboard. the dynamic scores are recalculated without the team's solves
+func (app *app) SetTeamHidden(tid uint32, hidden bool) error {
+	team, err := app.GetTeam(tid)
+	if err != nil {
+		return err
+	}
+	if team.IsHidden == hidden {
+		return nil
+	}
+	if err := app.repo.SetTeamHidden(tid, hidden); err != nil {
+		return err
+	}
+	if len(team.Submissions) == 0 {
+		return nil
+	}
+	return app.RecalcAllScores()
+}
+
+// DeleteTeam deletes the team with its members and submissions, then recalculates the dynamic scores
+func (app *app) DeleteTeam(actor *model.User, tid uint32) error {
+	if actor.TeamID == tid {
+		return ErrorMessage("you can not delete your own team")
+	}
+	team, err := app.GetTeam(tid)
+	if err != nil {
+		return err
+	}
+	if err := app.repo.DeleteTeam(tid); err != nil {
+		return err
+	}
+	if len(team.Submissions) == 0 {
+		return nil
+	}
+	return app.RecalcAllScores()
+}
+
+// GrantSolve makes the team solve the challenge at solvedAt (now when it is zero), for example when the flag checker was broken.
+// uid is the member credited with the solve, or nil. it returns the challenge with the updated score
+func (app *app) GrantSolve(actor *model.User, tid, cid uint32, uid *uint32, solvedAt int64) (*model.Challenge, error) {
+	team, err := app.GetTeam(tid)
+	if err != nil {
+		return nil, err
+	}
+	if uid != nil {
+		member := false
+		for _, u := range team.Users {
+			if u.ID == *uid {
+				member = true
+				break
+			}
+		}
+		if !member {
+			return nil, ErrorMessage("the user is not a member of the team")
+		}
+	}
+	if _, err := app.GetChallenge(cid); err != nil {
+		return nil, err
+	}
+	if solvedAt == 0 {
+		solvedAt = time.Now().Unix()
+	}
+	if solvedAt < 0 || solvedAt > time.Now().Unix() {
+		return nil, ErrorMessage("invalid solve time")
+	}
+
+	if err := app.repo.GrantSolve(tid, cid, uid, solvedAt, actor.ID); err != nil {
+		if model.IsDuplicated(err) {
+			return nil, ErrorMessage("the team already solved the challenge")
+		}
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("team not found")
+		}
+		return nil, err
+	}
+	if err := app.repo.AddSolvedChallenge(tid, cid); err != nil {
+		return nil, err
+	}
+	return app.updateSolvedChallenge(cid)
+}
+
+// RevokeSolve invalidates the solve of the team. the submission is kept as an invalid one
+func (app *app) RevokeSolve(tid, cid uint32) (*model.Challenge, error) {
+	if err := app.repo.InvalidateSolve(tid, cid); err != nil {
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("the team has not solved the challenge")
+		}
+		return nil, err
+	}
+	if err := app.repo.RemoveSolvedChallenge(tid, cid); err != nil {
+		return nil, err
+	}
+	return app.updateSolvedChallenge(cid)
+}
+
+// updateSolvedChallenge recalculates the score of the dynamic challenge after the solves are changed
+func (app *app) updateSolvedChallenge(cid uint32) (*model.Challenge, error) {
+	chal, err := app.GetChallenge(cid)
+	if err != nil {
+		return nil, err
+	}
+	if !chal.IsDynamic {
+		return chal, nil
+	}
+	conf, err := app.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := app.RecalcScore(conf.MinScore, chal.BaseScore, conf.EasySolves, conf.MediumSolves, cid); err != nil {
+		return nil, err
+	}
+	return app.GetChallenge(cid)
+}
+
+func (app *app) findUser(uid uint32) (*model.User, error) {
+	user, err := app.repo.FindUserByID(uid)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestBanUser(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testbanadmin", "testbanadmin@example.com", "password", "team-testbanadmin", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.RegisterUserCreateTeam("testban", "testban@example.com", "password", "team-testban", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _, err := app.LoginUser("testbanadmin", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, token, err := app.LoginUser("testban", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.BanUser(admin, admin.ID, "cheating"); err == nil {
+		t.Error("admins should not ban themselves")
+	}
+	if err := app.BanUser(admin, user.ID, ""); err == nil {
+		t.Error("reason should be required")
+	}
+	if _, err := app.GetLoginUser(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BanUser(admin, user.ID, "cheating"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.GetLoginUser(token); err == nil || !IsErrorMessage(err) {
+		t.Errorf("sessions of the banned user should be revoked, err: %v", err)
+	}
+	if _, _, err := app.LoginUser("testban", "password", "", nil); err == nil || err.Error() != "your account is banned: cheating" {
+		t.Errorf("login should be rejected with the reason, err: %v", err)
+	}
+
+	if err := app.UnbanUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := app.LoginUser("testban", "password", "", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMoveUser(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testmove1", "testmove1@example.com", "password", "team-testmove1", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.RegisterUserCreateTeam("testmove2", "testmove2@example.com", "password", "team-testmove2", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _, err := app.LoginUser("testmove1", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testmove2", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.MoveUser(admin, user.ID, user.TeamID); err == nil {
+		t.Error("moving to the same team should fail")
+	}
+	if err := app.MoveUser(admin, user.ID, admin.TeamID); err != nil {
+		t.Fatal(err)
+	}
+	team, err := app.GetTeam(admin.TeamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(team.Users) != 2 {
+		t.Errorf("expected 2 members, but got %d", len(team.Users))
+	}
+	oldTeam, err := app.GetTeam(user.TeamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(oldTeam.Users) != 0 || oldTeam.CaptainID != nil {
+		t.Errorf("old team should be empty: %+v", oldTeam)
+	}
+
+	if err := app.DeleteTeam(admin, admin.TeamID); err == nil {
+		t.Error("admins should not delete their own team")
+	}
+	if err := app.DeleteTeam(admin, oldTeam.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.GetTeam(oldTeam.ID); err == nil {
+		t.Error("team should be deleted")
+	}
+}
+
+func TestGrantRevokeSolve(t *testing.T) {
+	app, repo := newTestApp(t, nil)
+
+	err := app.RegisterUserCreateTeam("testgrant", "testgrant@example.com", "password", "team-testgrant", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _, err := app.LoginUser("testgrant", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid, err := repo.RegisterChallenge("testgrant", "zer0pts{testgrant}", "", "misc", "easy", "", nil, 500, true, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.GrantSolve(admin, admin.TeamID, cid, &admin.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.GrantSolve(admin, admin.TeamID, cid, nil, 0); err == nil {
+		t.Error("granted twice")
+	}
+	solved, err := app.TeamSolvedChallengeIDs(admin.TeamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsID(solved, cid) {
+		t.Errorf("the solved set should contain the challenge: %v", solved)
+	}
+	team, err := app.GetTeam(admin.TeamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(team.Submissions) != 1 || team.Submissions[0].SubmittedAt != 1 {
+		t.Errorf("the solve should be at the chosen time: %v", team.Submissions)
+	}
+
+	if _, err := app.RevokeSolve(admin.TeamID, cid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.RevokeSolve(admin.TeamID, cid); err == nil {
+		t.Error("revoked twice")
+	}
+	solved, err = app.TeamSolvedChallengeIDs(admin.TeamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsID(solved, cid) {
+		t.Errorf("the solved set should not contain the challenge: %v", solved)
+	}
+}
+
+func containsID(ids []uint32, id uint32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const (
+	// APITokenPrefix makes the tokens easy to find by secret scanners
+	APITokenPrefix        = "zpt_"
+	APITokenNameMaxLength = 64
+	MaxAPITokens          = 20
+)
+
+var apiTokenScopes = []string{model.ScopeReadChallenges, model.ScopeSubmit, model.ScopeAdmin}
+
+type APITokenApp interface {
+	CreateAPIToken(user *model.User, sessionToken, name string, scopes []string, expiresAt *int64) (*model.APIToken, string, error)
+	ListAPITokens(user *model.User) ([]*model.APIToken, error)
+	RevokeAPIToken(user *model.User, id uint32) error
+	GetAPITokenUser(token, scope string) (*model.User, error)
+}
+
+// CreateAPIToken returns the created token and its secret. the secret is not stored, so it is shown only once.
+// admin scope needs a session which satisfies CheckAdminSession, otherwise a token could bypass the second factor
+func (app *app) CreateAPIToken(user *model.User, sessionToken, name string, scopes []string, expiresAt *int64) (*model.APIToken, string, error) {
+	if name == "" {
+		return nil, "", ErrorMessage("name is required")
+	}
+	if len(name) > APITokenNameMaxLength {
+		return nil, "", ErrorMessage(fmt.Sprintf("name must be at most %d bytes", APITokenNameMaxLength))
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if containsScope(scopes, model.ScopeAdmin) {
+		if !user.IsAdmin {
+			return nil, "", ErrorMessage("admin scope is only for admins")
+		}
+		if err := app.CheckAdminSession(sessionToken); err != nil {
+			return nil, "", err
+		}
+	}
+	if expiresAt != nil && *expiresAt <= time.Now().Unix() {
+		return nil, "", ErrorMessage("expiry must be in the future")
+	}
+
+	tokens, err := app.repo.ListAPITokens(user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(tokens) >= MaxAPITokens {
+		return nil, "", ErrorMessage(fmt.Sprintf("you can have at most %d API tokens", MaxAPITokens))
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", fmt.Errorf("%w", err)
+	}
+	secret := APITokenPrefix + hex.EncodeToString(b)
+	id, err := app.repo.NewAPIToken(user.ID, name, hashAPIToken(secret), scopes, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &model.APIToken{
+		ID:        id,
+		UserID:    user.ID,
+		Name:      name,
+		ScopeList: scopes,
+		ExpiresAt: expiresAt,
+	}, secret, nil
+}
+
+func (app *app) ListAPITokens(user *model.User) ([]*model.APIToken, error) {
+	return app.repo.ListAPITokens(user.ID)
+}
+
+func (app *app) RevokeAPIToken(user *model.User, id uint32) error {
+	if err := app.repo.RevokeAPIToken(user.ID, id); err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("api token not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// GetAPITokenUser authenticates a request with the token. the token must have the scope
+func (app *app) GetAPITokenUser(token, scope string) (*model.User, error) {
+	t, err := app.repo.FindAPITokenByHash(hashAPIToken(token))
+	if err != nil {
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("invalid token")
+		}
+		return nil, err
+	}
+	if !containsScope(t.ScopeList, scope) {
+		return nil, ErrorMessage(fmt.Sprintf("the token does not have %s scope", scope))
+	}
+
+	user, err := app.repo.FindUserByID(t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if scope == model.ScopeAdmin && !user.IsAdmin {
+		return nil, ErrorMessage("invalid token")
+	}
+	if err := checkBanned(user); err != nil {
+		return nil, err
+	}
+	if err := app.repo.TouchAPIToken(t.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrorMessage("at least one scope is required")
+	}
+	normalized := make([]string, 0, len(scopes))
+	for _, s := range apiTokenScopes {
+		if containsScope(scopes, s) {
+			normalized = append(normalized, s)
+		}
+	}
+	for _, s := range scopes {
+		if !containsScope(apiTokenScopes, s) {
+			return nil, ErrorMessage(fmt.Sprintf("unknown scope %s", s))
+		}
+	}
+	return normalized, nil
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestAPIToken(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testapitoken", "testapitoken@example.com", "password", "team-testapitoken", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, session, err := app.LoginUser("testapitoken", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Hour).Unix()
+	var invalidCases = []struct {
+		name      string
+		scopes    []string
+		expiresAt *int64
+	}{
+		{"", []string{model.ScopeSubmit}, nil},
+		{"bot", []string{}, nil},
+		{"bot", []string{"unknown"}, nil},
+		{"bot", []string{model.ScopeAdmin}, nil},
+		{"bot", []string{model.ScopeSubmit}, &past},
+	}
+	for _, c := range invalidCases {
+		if _, _, err := app.CreateAPIToken(user, session, c.name, c.scopes, c.expiresAt); err == nil {
+			t.Errorf("case %v should fail", c)
+		}
+	}
+
+	token, secret, err := app.CreateAPIToken(user, session, "bot", []string{model.ScopeSubmit, model.ScopeReadChallenges}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u, err := app.GetAPITokenUser(secret, model.ScopeSubmit); err != nil || u.ID != user.ID {
+		t.Errorf("token should be accepted, user: %v, err: %v", u, err)
+	}
+	if _, err := app.GetAPITokenUser(secret, model.ScopeAdmin); err == nil {
+		t.Error("token without admin scope should be rejected")
+	}
+	if _, err := app.GetAPITokenUser(secret+"x", model.ScopeSubmit); err == nil {
+		t.Error("wrong token should be rejected")
+	}
+
+	tokens, err := app.ListAPITokens(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].ID != token.ID || tokens[0].LastUsedAt == nil {
+		t.Errorf("wrong tokens: %v", tokens)
+	}
+
+	if err := app.RevokeAPIToken(user, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.GetAPITokenUser(secret, model.ScopeSubmit); err == nil {
+		t.Error("revoked token should be rejected")
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const (
+	AuditLogDefaultLimit = 50
+	AuditLogMaxLimit     = 500
+)
+
+type AuditApp interface {
+	RecordAudit(log *model.AuditLog, before, after interface{}) error
+	SearchAuditLogs(filter *model.AuditFilter, cursor string, limit int) ([]*model.AuditLog, string, error)
+}
+
+// RecordAudit appends the log of an admin action with the diff of the object before and after the action.
+// before and after are compared by their JSON, so the fields hidden from JSON are not recorded
+func (app *app) RecordAudit(log *model.AuditLog, before, after interface{}) error {
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	log.Diff = diff
+	if log.ActedAt == 0 {
+		log.ActedAt = time.Now().Unix()
+	}
+	return app.repo.InsertAuditLog(log)
+}
+
+// SearchAuditLogs returns a page of the audit logs and the cursor of the next page. the cursor is empty on the last page
+func (app *app) SearchAuditLogs(filter *model.AuditFilter, cursor string, limit int) ([]*model.AuditLog, string, error) {
+	if limit <= 0 {
+		limit = AuditLogDefaultLimit
+	}
+	if limit > AuditLogMaxLimit {
+		limit = AuditLogMaxLimit
+	}
+	if filter.Since != nil && filter.Until != nil && *filter.Since >= *filter.Until {
+		return nil, "", ErrorMessage("since must be before until")
+	}
+
+	var c *uint64
+	if cursor != "" {
+		id, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return nil, "", ErrorMessage("invalid cursor")
+		}
+		c = &id
+	}
+
+	// one more row to know whether the next page exists
+	logs, err := app.repo.ListAuditLogs(filter, c, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(logs) <= limit {
+		return logs, "", nil
+	}
+	logs = logs[:limit]
+	return logs, strconv.FormatUint(logs[limit-1].ID, 10), nil
+}
+
+// auditChange is a field changed by an admin action
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditDiff returns the JSON of the top level fields which differ between before and after.
+// nil means the object did not exist (created or deleted). values which are not objects are recorded
+// as the field "". it returns "" when both are nil, i.e. the action has no target object
+func auditDiff(before, after interface{}) (string, error) {
+	if before == nil && after == nil {
+		return "", nil
+	}
+	b, err := auditFields(before)
+	if err != nil {
+		return "", err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return "", err
+	}
+
+	diff := make(map[string]auditChange)
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !reflect.DeepEqual(v, w) {
+			diff[k] = auditChange{Before: v, After: a[k]}
+		}
+	}
+	for k, w := range a {
+		if _, ok := b[k]; !ok {
+			diff[k] = auditChange{Before: nil, After: w}
+		}
+	}
+
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if m, ok := value.(map[string]interface{}); ok {
+		return m, nil
+	}
+	fields[""] = value
+	return fields, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestAuditDiff(t *testing.T) {
+	type object struct {
+		Name   string `json:"name"`
+		IsOpen bool   `json:"is_open"`
+		Secret string `json:"-"`
+	}
+	var testCases = []struct {
+		before   interface{}
+		after    interface{}
+		expected string
+	}{
+		{nil, nil, ""},
+		{&object{"a", false, "x"}, &object{"a", false, "y"}, `{}`},
+		{&object{"a", false, ""}, &object{"a", true, ""}, `{"is_open":{"before":false,"after":true}}`},
+		{nil, &object{"a", true, ""}, `{"is_open":{"before":null,"after":true},"name":{"before":null,"after":"a"}}`},
+		{&object{"a", true, ""}, (*object)(nil), `{"is_open":{"before":true,"after":null},"name":{"before":"a","after":null}}`},
+		{map[string]interface{}{"chal": map[string]int{"score": 500}}, map[string]interface{}{"chal": map[string]int{"score": 400}}, `{"chal":{"before":{"score":500},"after":{"score":400}}}`},
+		{1, 2, `{"":{"before":1,"after":2}}`},
+	}
+	for i, c := range testCases {
+		diff, err := auditDiff(c.before, c.after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff != c.expected {
+			t.Errorf("case %d: expected %s, but %s", i, c.expected, diff)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	app := newApp(t)
+	// the table is not cleared between the tests
+	actor := uint32(time.Now().UnixNano())
+	target := fmt.Sprintf("user:%d", actor)
+
+	for i := 0; i < 3; i++ {
+		err := app.RecordAudit(&model.AuditLog{
+			ActorID:   actor,
+			ActorName: "admin",
+			Method:    "POST",
+			Path:      "/admin/set-user-hidden",
+			Target:    target,
+			Status:    200,
+			Request:   `{"user_id":2}`,
+		}, map[string]bool{"is_hidden": i%2 == 1}, map[string]bool{"is_hidden": i%2 == 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.RecordAudit(&model.AuditLog{ActorID: actor + 1, ActorName: "other", Method: "POST", Path: "/admin/force-logout", Target: target, Status: 200}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, next, err := app.SearchAuditLogs(&model.AuditFilter{ActorID: &actor}, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || next == "" {
+		t.Fatalf("expected the first page of 2 logs, but %d logs and next %q", len(logs), next)
+	}
+	if logs[0].ID <= logs[1].ID {
+		t.Errorf("logs should be the newest first: %d, %d", logs[0].ID, logs[1].ID)
+	}
+	if logs[0].Diff != `{"is_hidden":{"before":false,"after":true}}` {
+		t.Errorf("wrong diff: %s", logs[0].Diff)
+	}
+	if logs[0].ActedAt == 0 {
+		t.Errorf("acted_at should be set")
+	}
+
+	logs, next, err = app.SearchAuditLogs(&model.AuditFilter{ActorID: &actor}, next, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || next != "" {
+		t.Fatalf("expected the last page of 1 log, but %d logs and next %q", len(logs), next)
+	}
+
+	logs, _, err = app.SearchAuditLogs(&model.AuditFilter{Target: target, Path: "/admin/force-logout"}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].ActorName != "other" || logs[0].Diff != "" {
+		t.Errorf("wrong logs of force-logout: %v", logs)
+	}
+
+	if _, _, err := app.SearchAuditLogs(&model.AuditFilter{}, "x", 0); !IsErrorMessage(err) {
+		t.Errorf("invalid cursor should be an error message, but %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"log"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type BackfillApp interface {
+	Backfill() error
+}
+
+// Backfill fills the columns added after users and teams were registered. it runs at startup
+// and does nothing when every row is already filled
+func (app *app) Backfill() error {
+	n, err := app.repo.FillTeamCaptains()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("set the captains of %d teams to their earliest members", n)
+	}
+	return app.fillTeamSkeletons()
+}
+
+// fillTeamSkeletons sets the skeletons of the old teams so that new teamnames are checked against them.
+// when two old teams already have confusable names, the earlier one keeps the skeleton
+func (app *app) fillTeamSkeletons() error {
+	teams, err := app.repo.ListTeamsWithoutSkeleton()
+	if err != nil {
+		return err
+	}
+	filled := 0
+	for _, t := range teams {
+		err := app.repo.SetTeamSkeleton(t.ID, teamNameSkeleton(t.Teamname))
+		if err != nil {
+			if model.IsDuplicated(err) {
+				log.Printf("the teamname of team %d (%s) is confusable with another team", t.ID, t.Teamname)
+				continue
+			}
+			return err
+		}
+		filled++
+	}
+	if filled > 0 {
+		log.Printf("set the skeletons of %d teams", filled)
+	}
+	return nil
+}
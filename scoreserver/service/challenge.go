@@ -21,8 +21,10 @@ type ChallengeApp interface {
 
 	SubmitFlag(user *model.User, flag string) (*model.Challenge, bool, error)
 	RecalcScore(min, max, e, m int, cid uint32) error
+	RecalcAllScores() error
 
 	TeamSolvedChallengeIDs(tid uint32) ([]uint32, error)
+	GetChallengeStatistics(id uint32) (*model.ChallengeStatistics, error)
 
 	CheckSubmittable(tid uint32) (bool, error)
 }
@@ -68,6 +70,17 @@ func (app *app) SubmitFlag(user *model.User, flag string) (*model.Challenge, boo
 	if !started {
 		return nil, false, ErrorMessage(CTFNotStartedYetMessage)
 	}
+	if err := checkBanned(user); err != nil {
+		return nil, false, err
+	}
+
+	conf, err := app.GetConfig()
+	if err != nil {
+		return nil, false, err
+	}
+	if conf.EmailVerification && !user.EmailVerified {
+		return nil, false, ErrorMessage(EmailNotVerifiedMessage)
+	}
 
 	team, err := app.repo.FindUserTeam(user.ID)
 	if err != nil {
@@ -113,18 +126,24 @@ func (app *app) SubmitFlag(user *model.User, flag string) (*model.Challenge, boo
 		return nil, false, err
 	}
 
+	// the team of a solo user is the user themselves
+	who := fmt.Sprintf("%s@%s", user.Username, team.Teamname)
+	if conf.SoloMode {
+		who = user.Username
+	}
+
 	if !valid && !correct {
-		if err := app.webhook.Send(fmt.Sprintf("`%s@%s` send flag `%s`, but wrong", user.Username, team.Teamname, flag)); err != nil {
+		if err := app.webhook.Send(fmt.Sprintf("`%s` send flag `%s`, but wrong", who, flag)); err != nil {
 			log.Println(err)
 		}
 	} else if !valid && correct {
 		/*
-			if err := app.webhook.Send(fmt.Sprintf("`%s@%s` send flag `%s` and solved `%s` but already solved", user.Username, team.Teamname, flag, chal.Name)); err != nil {
+			if err := app.webhook.Send(fmt.Sprintf("`%s` send flag `%s` and solved `%s` but already solved", who, flag, chal.Name)); err != nil {
 				log.Println(err)
 			}
 		*/
 	} else {
-		if err := app.webhook.Send(fmt.Sprintf("`%s@%s` send flag `%s` and solved `%s` :100:", user.Username, team.Teamname, flag, chal.Name)); err != nil {
+		if err := app.webhook.Send(fmt.Sprintf("`%s` send flag `%s` and solved `%s` :100:", who, flag, chal.Name)); err != nil {
 			log.Println(err)
 		}
 		app.repo.AddSolvedChallenge(uint32(tid.Int64), uint32(cid.Int64))
@@ -147,6 +166,28 @@ func (app *app) RecalcScore(min, max, e, m int, cid uint32) error {
 	return app.repo.UpdateScore(cid, p)
 }
 
+// RecalcAllScores recalculates the scores of the open dynamic challenges
+func (app *app) RecalcAllScores() error {
+	chals, err := app.ListOpenChallenges()
+	if err != nil {
+		return err
+	}
+	conf, err := app.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	for _, chal := range chals {
+		if !chal.IsDynamic {
+			continue
+		}
+		if err := app.RecalcScore(conf.MinScore, chal.BaseScore, conf.EasySolves, conf.MediumSolves, chal.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (app *app) TeamSolvedChallengeIDs(tid uint32) ([]uint32, error) {
 	return app.repo.TeamSolvedChallenges(tid)
 }
@@ -154,3 +195,77 @@ func (app *app) TeamSolvedChallengeIDs(tid uint32) ([]uint32, error) {
 func (app *app) CheckSubmittable(tid uint32) (bool, error) {
 	return app.repo.CheckSubmitAvailable(tid)
 }
+
+func (app *app) GetChallengeStatistics(id uint32) (*model.ChallengeStatistics, error) {
+	chal, err := app.GetChallenge(id)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := app.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := app.repo.ListCorrectSubmission(id)
+	if err != nil {
+		return nil, err
+	}
+	// hidden teams are not on the scoreboard, so their solves are not counted either
+	teams, err := app.repo.ListTeams(true)
+	if err != nil {
+		return nil, err
+	}
+	teamMap := make(map[uint32]*model.Team)
+	for _, t := range teams {
+		teamMap[t.ID] = t
+	}
+
+	stats := &model.ChallengeStatistics{
+		ChallengeID:     chal.ID,
+		Name:            chal.Name,
+		SolvesOverTime:  make([]model.SolveCount, 0),
+		SolvesByCountry: make(map[string]int),
+		Score:           chal.Score,
+		BaseScore:       chal.BaseScore,
+	}
+
+	// submissions are ordered by submitted_at
+	solved := make(map[uint32]struct{})
+	for _, s := range submissions {
+		if s.TeamID == nil {
+			continue
+		}
+		tid := *s.TeamID
+		t, ok := teamMap[tid]
+		if !ok {
+			continue
+		}
+
+		if !s.IsValid {
+			if _, ok := solved[tid]; ok {
+				stats.RepeatSolves++
+			}
+			continue
+		}
+
+		solved[tid] = struct{}{}
+		stats.Solves++
+		stats.SolvesOverTime = append(stats.SolvesOverTime, model.SolveCount{
+			Time:   s.SubmittedAt,
+			Solves: stats.Solves,
+		})
+
+		stats.SolvesByCountry[t.CountryCode]++
+
+		if stats.FirstBlood == nil {
+			stats.FirstBlood = &model.FirstBlood{
+				TeamID:   tid,
+				Teamname: t.Teamname,
+				SolvedAt: s.SubmittedAt,
+			}
+			elapsed := s.SubmittedAt - conf.StartAt
+			stats.TimeToFirstSolve = &elapsed
+		}
+	}
+
+	return stats, nil
+}
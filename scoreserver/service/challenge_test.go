@@ -0,0 +1,63 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestChallengeStatisticsHiddenTeam(t *testing.T) {
+	app, repo := newTestApp(t, nil)
+
+	err := app.RegisterUserCreateTeam("teststatshidden", "teststatshidden@example.com", "password", "team-teststatshidden", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.RegisterUserCreateTeam("teststatsvisible", "teststatsvisible@example.com", "password", "team-teststatsvisible", "USA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, _, err := app.LoginUser("teststatshidden", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	visible, _, err := app.LoginUser("teststatsvisible", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SetTeamHidden(hidden.TeamID, true); err != nil {
+		t.Fatal(err)
+	}
+	cid, err := repo.RegisterChallenge("teststatshidden", "zer0pts{teststatshidden}", "", "misc", "easy", "", nil, 500, true, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the hidden team solves first
+	for i, u := range []*model.User{hidden, visible} {
+		err := repo.InsertSubmission(
+			sql.NullInt64{Int64: int64(cid), Valid: true},
+			sql.NullInt64{Int64: int64(u.ID), Valid: true},
+			sql.NullInt64{Int64: int64(u.TeamID), Valid: true},
+			"zer0pts{teststatshidden}", int64(100+i), true, true,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := app.GetChallengeStatistics(cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Solves != 1 {
+		t.Errorf("only the visible team should be counted, but %d solves", stats.Solves)
+	}
+	if stats.FirstBlood == nil || stats.FirstBlood.TeamID != visible.TeamID || stats.FirstBlood.Teamname != "team-teststatsvisible" {
+		t.Errorf("the first blood should be the visible team: %+v", stats.FirstBlood)
+	}
+	if stats.SolvesByCountry["JPN"] != 0 || stats.SolvesByCountry["USA"] != 1 {
+		t.Errorf("wrong solves by country: %v", stats.SolvesByCountry)
+	}
+}
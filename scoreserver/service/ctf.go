@@ -9,6 +9,9 @@ import (
 const (
 	CTFNotStartedYetMessage = "CTF has not started yet"
 	CTFFinishedMessage      = "CTF has been finished"
+	EmailNotVerifiedMessage = "please verify your email address before submitting flags"
+	BannedMessage           = "your account is banned: %s"
+	SoloModeMessage         = "teams are not available in solo mode"
 )
 
 type CTFApp interface {
@@ -19,6 +22,10 @@ type CTFApp interface {
 	SetLock(second, count, duration int) error
 	SetSolves(easy, medium int) error
 	SetMinScore(score int) error
+	SetMaxTeamSize(size int) error
+	SetEmailVerification(enabled bool) error
+	SetRequireAdminTOTP(required bool) error
+	SetSoloMode(enabled bool) error
 	CTFStarted(t time.Time) (bool, error)
 	CTFFinished(t time.Time) (bool, error)
 	CTFNowRunning(t time.Time) (bool, error)
@@ -57,6 +64,37 @@ func (app *app) SetMinScore(score int) error {
 	return app.repo.SetMinScore(score)
 }
 
+func (app *app) SetMaxTeamSize(size int) error {
+	if size < 0 {
+		return ErrorMessage("max_team_size must be 0 (unlimited) or positive")
+	}
+	return app.repo.SetMaxTeamSize(size)
+}
+
+// SetEmailVerification switches the email verification. when it is enabled, the users registered while it was disabled
+// are regarded as verified, because they were never sent a verification mail and would not be able to submit.
+// the users registered while it was enabled have a token, so they still have to verify
+func (app *app) SetEmailVerification(enabled bool) error {
+	if err := app.repo.SetEmailVerification(enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+	_, err := app.repo.VerifyUsersWithoutVerificationToken()
+	return err
+}
+
+func (app *app) SetRequireAdminTOTP(required bool) error {
+	return app.repo.SetRequireAdminTOTP(required)
+}
+
+// SetSoloMode makes every user their own team. it should be set before the registration opens,
+// because the existing teams are not split
+func (app *app) SetSoloMode(enabled bool) error {
+	return app.repo.SetSoloMode(enabled)
+}
+
 func (app *app) CTFStarted(t time.Time) (bool, error) {
 	conf, err := app.GetConfig()
 	if err != nil {
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+// DashboardSubmissionWindow is the period of the submissions per minute
+const DashboardSubmissionWindow = time.Hour * 1
+
+// DashboardRecentSolves is the number of the recent solves on the dashboard
+const DashboardRecentSolves = 20
+
+type DashboardApp interface {
+	GetDashboard() (*model.Dashboard, error)
+}
+
+func (app *app) GetDashboard() (*model.Dashboard, error) {
+	registrations, err := app.repo.CountRegistrations()
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := app.repo.CountSubmissions(time.Now().Add(-DashboardSubmissionWindow).Unix())
+	if err != nil {
+		return nil, err
+	}
+	solves, err := app.repo.ListRecentSolves(DashboardRecentSolves)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := app.lockedTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Dashboard{
+		Registrations: registrations,
+		Clients:       app.ClientCount(),
+		Submissions:   submissions,
+		LockedTeams:   locked,
+		RecentSolves:  solves,
+	}, nil
+}
+
+// lockedTeams returns the teams whose submission is locked, the longest remaining first
+func (app *app) lockedTeams() ([]*model.LockedTeam, error) {
+	locks, err := app.repo.ListSubmissionLocks()
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make([]*model.LockedTeam, 0, len(locks))
+	for tid, ttl := range locks {
+		team, err := app.repo.FindTeamByID(tid)
+		if err != nil {
+			if model.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		teams = append(teams, &model.LockedTeam{
+			TeamID:    tid,
+			Teamname:  team.Teamname,
+			ExpiresIn: int64((ttl + time.Second - 1) / time.Second),
+		})
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].ExpiresIn > teams[j].ExpiresIn
+	})
+	return teams, nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testdashboard", "testdashboard@example.com", "password", "team-testdashboard", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testdashboard", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.LockSubmission(user.TeamID, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	dashboard, err := app.GetDashboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboard.Registrations) == 0 {
+		t.Error("registrations should be counted")
+	}
+	found := false
+	for _, team := range dashboard.LockedTeams {
+		if team.TeamID == user.TeamID {
+			found = team.Teamname == "team-testdashboard" && team.ExpiresIn > 0
+		}
+	}
+	if !found {
+		t.Errorf("locked team not found: %v", dashboard.LockedTeams)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
+
+	// decoders for uploaded icons
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"golang.org/x/image/draw"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/storage"
+)
+
+const (
+	IconSize         = 256
+	IconMaxBytes     = 1 << 20
+	IconMaxDimension = 4096
+	IconPathPrefix   = "/icons/"
+)
+
+var iconFormats = map[string]struct{}{
+	"png":  struct{}{},
+	"jpeg": struct{}{},
+	"gif":  struct{}{},
+}
+
+type IconApp interface {
+	SetUserIcon(uid uint32, data []byte) (string, error)
+	SetTeamIcon(tid uint32, data []byte) (string, error)
+	GetIcon(name string) ([]byte, error)
+}
+
+func (app *app) SetUserIcon(uid uint32, data []byte) (string, error) {
+	iconPath, err := app.saveIcon(data)
+	if err != nil {
+		return "", err
+	}
+	if err := app.repo.SetUserIcon(uid, &iconPath); err != nil {
+		return "", err
+	}
+	return iconPath, nil
+}
+
+func (app *app) SetTeamIcon(tid uint32, data []byte) (string, error) {
+	iconPath, err := app.saveIcon(data)
+	if err != nil {
+		return "", err
+	}
+	if err := app.repo.SetTeamIcon(tid, &iconPath); err != nil {
+		return "", err
+	}
+	return iconPath, nil
+}
+
+func (app *app) GetIcon(name string) ([]byte, error) {
+	data, err := app.storage.Get(name)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, ErrorMessage("icon not found")
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// saveIcon stores the normalized icon and returns the path it is served at.
+// the file name is derived from the content, so the same image is stored once
+func (app *app) saveIcon(data []byte) (string, error) {
+	icon, err := normalizeIcon(data)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(icon)
+	name := hex.EncodeToString(hash[:16]) + ".png"
+	if err := app.storage.Put(name, icon); err != nil {
+		return "", err
+	}
+	return IconPathPrefix + name, nil
+}
+
+// normalizeIcon validates the uploaded image, crops it to a square and re-encodes it as IconSize x IconSize PNG.
+// re-encoding drops every metadata (EXIF and so on) of the original file
+func normalizeIcon(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrorMessage("icon is required")
+	}
+	if len(data) > IconMaxBytes {
+		return nil, ErrorMessage("icon is too large. the limit is 1MB")
+	}
+
+	conf, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrorMessage("icon must be a PNG, JPEG or GIF image")
+	}
+	if _, ok := iconFormats[format]; !ok {
+		return nil, ErrorMessage("icon must be a PNG, JPEG or GIF image")
+	}
+	if conf.Width <= 0 || conf.Height <= 0 || conf.Width > IconMaxDimension || conf.Height > IconMaxDimension {
+		return nil, ErrorMessage("icon dimensions are too large")
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrorMessage("failed to decode the icon")
+	}
+
+	b := src.Bounds()
+	size := b.Dx()
+	if b.Dy() < size {
+		size = b.Dy()
+	}
+	x := b.Min.X + (b.Dx()-size)/2
+	y := b.Min.Y + (b.Dy()-size)/2
+	crop := image.Rect(x, y, x+size, y+size)
+
+	dst := image.NewRGBA(image.Rect(0, 0, IconSize, IconSize))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestNormalizeIcon(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	icon, err := normalizeIcon(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(icon))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != IconSize || img.Bounds().Dy() != IconSize {
+		t.Errorf("unexpected size: %v", img.Bounds())
+	}
+
+	var testCases = []struct {
+		data []byte
+	}{
+		{nil},
+		{[]byte("<svg></svg>")},
+		{make([]byte, IconMaxBytes+1)},
+	}
+	for _, c := range testCases {
+		if _, err := normalizeIcon(c.data); err == nil {
+			t.Errorf("invalid icon accepted: %d bytes", len(c.data))
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type IdentityApp interface {
+	LoginWithIdentity(identity *model.Identity, info *model.SessionInfo) (*model.User, string, error)
+	JoinIdentityToTeam(identity *model.Identity, username, token, inviteCode string, info *model.SessionInfo) (*model.User, string, error)
+	RegisterIdentityCreateTeam(identity *model.Identity, username, teamName, countryCode, inviteCode string, info *model.SessionInfo) (*model.User, string, error)
+}
+
+// LoginWithIdentity logs in the user linked to the identity.
+// an identity which is not linked yet is linked to the user who has the same email only when the provider verified the email.
+// it returns NotFoundError when there are no users for the identity, then the identity should be registered
+func (app *app) LoginWithIdentity(identity *model.Identity, info *model.SessionInfo) (*model.User, string, error) {
+	user, err := app.repo.FindUserByIdentity(identity.Issuer, identity.Subject)
+	if err != nil && !model.IsNotFound(err) {
+		return nil, "", err
+	}
+
+	if err != nil {
+		if !identity.EmailVerified || identity.Email == "" {
+			return nil, "", model.NotFoundError("user")
+		}
+		user, err = app.repo.FindUserByEmail(identity.Email)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := app.repo.AddUserIdentity(user.ID, identity); err != nil {
+			return nil, "", err
+		}
+		if !user.EmailVerified {
+			if err := app.repo.SetEmailVerified(user.ID, true); err != nil {
+				return nil, "", err
+			}
+			user.EmailVerified = true
+		}
+	}
+
+	if err := checkBanned(user); err != nil {
+		return nil, "", err
+	}
+	// the identity provider does not tell whether our second factor is satisfied
+	if user.TOTPEnabled {
+		return nil, "", ErrorMessage("two-factor authentication is enabled. please login with your password")
+	}
+
+	token, err := app.issueLoginToken(user.ID, false, info)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+func (app *app) JoinIdentityToTeam(identity *model.Identity, username, token, inviteCode string, info *model.SessionInfo) (*model.User, string, error) {
+	if err := app.checkIdentityAvailable(identity); err != nil {
+		return nil, "", err
+	}
+	uid, err := app.joinTeam(username, identity.Email, identityPassword(), token, inviteCode, identity.EmailVerified)
+	if err != nil {
+		return nil, "", err
+	}
+	return app.linkRegisteredIdentity(uid, identity, info)
+}
+
+func (app *app) RegisterIdentityCreateTeam(identity *model.Identity, username, teamName, countryCode, inviteCode string, info *model.SessionInfo) (*model.User, string, error) {
+	if err := app.checkIdentityAvailable(identity); err != nil {
+		return nil, "", err
+	}
+	uid, err := app.createTeamWithUser(username, identity.Email, identityPassword(), teamName, countryCode, inviteCode, identity.EmailVerified)
+	if err != nil {
+		return nil, "", err
+	}
+	return app.linkRegisteredIdentity(uid, identity, info)
+}
+
+func (app *app) checkIdentityAvailable(identity *model.Identity) error {
+	if identity.Email == "" {
+		return ErrorMessage("your identity provider did not share an email address")
+	}
+	_, err := app.repo.FindUserByIdentity(identity.Issuer, identity.Subject)
+	if err != nil && !model.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		return ErrorMessage("this account is already registered. please login")
+	}
+	return nil
+}
+
+func (app *app) linkRegisteredIdentity(uid uint32, identity *model.Identity, info *model.SessionInfo) (*model.User, string, error) {
+	if err := app.repo.AddUserIdentity(uid, identity); err != nil {
+		if model.IsDuplicated(err) {
+			return nil, "", ErrorMessage("this account is already registered. please login")
+		}
+		return nil, "", err
+	}
+	user, err := app.repo.FindUserByID(uid)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := app.issueLoginToken(uid, false, info)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// identityPassword is a random password for the users registered with an identity.
+// nobody knows it, so they have to use the password reset to login with a password
+func identityPassword() string {
+	return uuid.New().String()
+}
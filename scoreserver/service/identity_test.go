@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestLoginWithIdentity(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testidentitylink", "testidentitylink@example.com", "password", "team-testidentitylink", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unverified := &model.Identity{Issuer: "https://idp.example.com", Subject: "link", Email: "testidentitylink@example.com"}
+	if _, _, err := app.LoginWithIdentity(unverified, nil); !model.IsNotFound(err) {
+		t.Errorf("identity with an unverified email must not be linked, err: %v", err)
+	}
+
+	verified := &model.Identity{Issuer: "https://idp.example.com", Subject: "link", Email: "testidentitylink@example.com", EmailVerified: true}
+	user, token, err := app.LoginWithIdentity(verified, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "testidentitylink" || !user.EmailVerified || token == "" {
+		t.Errorf("wrong login: %+v, token: %s", user, token)
+	}
+
+	// linked by the subject, so the email does not matter any more
+	changed := &model.Identity{Issuer: "https://idp.example.com", Subject: "link", Email: "changed@example.com"}
+	if user, _, err := app.LoginWithIdentity(changed, nil); err != nil || user.Username != "testidentitylink" {
+		t.Errorf("linked identity should login, user: %v, err: %v", user, err)
+	}
+}
+
+func TestRegisterIdentity(t *testing.T) {
+	app := newApp(t)
+
+	identity := &model.Identity{Issuer: "https://idp.example.com", Subject: "register", Email: "testidentityregister@example.com", EmailVerified: true}
+	if _, _, err := app.LoginWithIdentity(identity, nil); !model.IsNotFound(err) {
+		t.Fatalf("new identity should not be found, err: %v", err)
+	}
+
+	user, token, err := app.RegisterIdentityCreateTeam(identity, "testidentityregister", "team-testidentityregister", "JPN", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != identity.Email || !user.EmailVerified || token == "" {
+		t.Errorf("wrong registration: %+v, token: %s", user, token)
+	}
+	if _, err := app.GetLoginUser(token); err != nil {
+		t.Error(err)
+	}
+
+	if _, _, err := app.RegisterIdentityCreateTeam(identity, "testidentityregister2", "team-testidentityregister2", "JPN", "", nil); err == nil {
+		t.Error("identity should not be registered twice")
+	}
+	if _, _, err := app.LoginWithIdentity(identity, nil); err != nil {
+		t.Error(err)
+	}
+}
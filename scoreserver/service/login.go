@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+// LoginFailedMessage is returned for both unknown usernames and wrong passwords, not to leak which usernames exist
+const LoginFailedMessage = "wrong username or password"
+
+// LoginLockedMessage is returned while the account or the IP address is locked out
+const LoginLockedMessage = "too many failed login attempts. try again in %d seconds"
+
+const (
+	// LoginFailureWindow is how long failed attempts are remembered after the last one
+	LoginFailureWindow = 24 * time.Hour
+	// AccountLoginThreshold is the number of failures allowed for an account before the lockout
+	AccountLoginThreshold = 5
+	// IPLoginThreshold is the number of failures allowed for an IP address. it is larger because of NAT
+	IPLoginThreshold = 20
+	// LoginLockBase is the first lockout. it doubles on every failure after the threshold
+	LoginLockBase = 1 * time.Minute
+	// LoginLockMax is the upper limit of the lockout
+	LoginLockMax = 1 * time.Hour
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash is a hash of the same cost as the users' ones. unknown usernames are checked against it,
+// so that the response time does not tell whether the username exists
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		h, err := hashPassword("dummy password")
+		if err != nil {
+			log.Fatal(err)
+		}
+		dummyHash = h
+	})
+	return dummyHash
+}
+
+func accountLoginKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginThrottleKeys returns the keys to count failures of the login attempt with their thresholds
+func loginThrottleKeys(username string, info *model.SessionInfo) map[string]int {
+	keys := map[string]int{
+		accountLoginKey(username): AccountLoginThreshold,
+	}
+	if info != nil && info.IP != "" {
+		keys[ipLoginKey(info.IP)] = IPLoginThreshold
+	}
+	return keys
+}
+
+// loginLockDuration returns the lockout after count failures. it is zero until the threshold
+func loginLockDuration(count, threshold int) time.Duration {
+	if count < threshold {
+		return 0
+	}
+	d := LoginLockBase
+	for i := threshold; i < count; i++ {
+		d *= 2
+		if d >= LoginLockMax {
+			return LoginLockMax
+		}
+	}
+	return d
+}
+
+// checkLoginLock rejects the login attempt while the account or the IP address is locked out
+func (app *app) checkLoginLock(username string, info *model.SessionInfo) error {
+	for key := range loginThrottleKeys(username, info) {
+		ttl, err := app.repo.GetLoginLock(key)
+		if err != nil {
+			return err
+		}
+		if ttl > 0 {
+			return ErrorMessage(fmt.Sprintf(LoginLockedMessage, int((ttl+time.Second-1)/time.Second)))
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts the failed attempt and locks the account or the IP address when it exceeds the threshold
+func (app *app) recordLoginFailure(username string, info *model.SessionInfo) error {
+	for key, threshold := range loginThrottleKeys(username, info) {
+		count, err := app.repo.IncrementLoginFailure(key, LoginFailureWindow)
+		if err != nil {
+			return err
+		}
+		d := loginLockDuration(count, threshold)
+		if d == 0 {
+			continue
+		}
+		if err := app.repo.LockLogin(key, d); err != nil {
+			return err
+		}
+
+		log.Printf("LOCK login of %s for %v after %d failures\n", key, d, count)
+		if err := app.webhook.Send(fmt.Sprintf("login of `%s` is locked for %v after %d failures", key, d, count)); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// loginFailed records the failure and returns err, or the error of recording it
+func (app *app) loginFailed(username string, info *model.SessionInfo, err error) error {
+	if e := app.recordLoginFailure(username, info); e != nil {
+		return e
+	}
+	return err
+}
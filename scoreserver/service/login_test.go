@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginLockDuration(t *testing.T) {
+	var testCases = []struct {
+		count    int
+		expected time.Duration
+	}{
+		{0, 0},
+		{AccountLoginThreshold - 1, 0},
+		{AccountLoginThreshold, LoginLockBase},
+		{AccountLoginThreshold + 1, 2 * LoginLockBase},
+		{AccountLoginThreshold + 2, 4 * LoginLockBase},
+		{AccountLoginThreshold + 100, LoginLockMax},
+	}
+	for _, c := range testCases {
+		if d := loginLockDuration(c.count, AccountLoginThreshold); d != c.expected {
+			t.Errorf("count %d: expected %v, but %v", c.count, c.expected, d)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testlockout", "testlockout@example.com", "password", "team-testlockout", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &model.SessionInfo{IP: "192.0.2.1"}
+
+	_, _, err1 := app.LoginUser("testlockout-unknown", "password", "", info)
+	_, _, err2 := app.LoginUser("testlockout", "wrongpassword", "", info)
+	if err1 == nil || err2 == nil || err1.Error() != err2.Error() {
+		t.Errorf("the errors should be the same: %v, %v", err1, err2)
+	}
+
+	for i := 1; i < AccountLoginThreshold; i++ {
+		if _, _, err := app.LoginUser("testlockout", "wrongpassword", "", info); err == nil {
+			t.Fatal("logged in with the wrong password")
+		}
+	}
+	if _, _, err := app.LoginUser("testlockout", "password", "", info); err == nil {
+		t.Error("the account should be locked")
+	}
+	if _, _, err := app.LoginUser("TESTLOCKOUT", "password", "", nil); err == nil {
+		t.Error("the account should be locked regardless of the case")
+	}
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("the dummy hash should cost as much as the users' ones, but %d", cost)
+	}
+}
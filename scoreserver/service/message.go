@@ -3,6 +3,7 @@ package service
 import (
 	"encoding/json"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -28,11 +29,15 @@ type MessageApp interface {
 	Send(msg []byte, loginRequired, adminRequired bool)
 	Add(c MessageClient)
 	Remove(c MessageClient)
+	ClientCount() int
 
-	NewClient(ws *websocket.Conn, user *model.User) MessageClient
+	NewClient(ws *websocket.Conn, user *model.User, mfa bool) MessageClient
 }
 
 type messageApp struct {
+	// the number of the clients connected to this server. it is read outside of HandleMessage
+	clientCount int64
+
 	msg     chan message
 	clients map[MessageClient]struct{}
 	add     chan MessageClient
@@ -43,6 +48,7 @@ type messageApp struct {
 
 type MessageClient interface {
 	User() *model.User
+	MFA() bool
 	Send(msg []byte)
 	SendHandler()
 	Close()
@@ -53,12 +59,18 @@ type messageClient struct {
 	service MessageApp
 	ws      *websocket.Conn
 	user    *model.User
+	mfa     bool
 }
 
 func (c *messageClient) User() *model.User {
 	return c.user
 }
 
+// MFA returns whether the session of the client was created with the second factor
+func (c *messageClient) MFA() bool {
+	return c.mfa
+}
+
 func (c *messageClient) Send(msg []byte) {
 	c.msg <- msg
 }
@@ -103,11 +115,12 @@ func newMessageApp() *messageApp {
 	}
 }
 
-func (app *app) NewClient(ws *websocket.Conn, user *model.User) MessageClient {
+func (app *app) NewClient(ws *websocket.Conn, user *model.User, mfa bool) MessageClient {
 	return &messageClient{
 		service: app,
 		ws:      ws,
 		user:    user,
+		mfa:     mfa,
 		msg:     make(chan []byte),
 	}
 }
@@ -132,26 +145,51 @@ func (app *app) HandleMessage() error {
 				log.Println(err)
 				break
 			}
-			for c, _ := range app.clients {
-				user := c.User()
-				if msg.LoginRequired && user == nil {
-					continue
+			requireAdminTOTP := false
+			if msg.AdminRequired {
+				conf, err := app.GetConfig()
+				if err != nil {
+					// admin messages are not sent when the config can not be checked
+					log.Println(err)
+					break
 				}
-				if msg.AdminRequired && (user == nil || user.IsAdmin == false) {
-					continue
+				requireAdminTOTP = conf.RequireAdminTOTP
+			}
+			for c, _ := range app.clients {
+				if receivable(msg, c, requireAdminTOTP) {
+					c.Send(msg.Body)
 				}
-				c.Send(msg.Body)
 			}
 
 		case c := <-app.add:
 			app.clients[c] = struct{}{}
+			atomic.StoreInt64(&app.clientCount, int64(len(app.clients)))
 
 		case c := <-app.remove:
 			delete(app.clients, c)
+			atomic.StoreInt64(&app.clientCount, int64(len(app.clients)))
 		}
 	}
 	return nil
 }
+
+// receivable checks the client can receive the message. admin messages need the same session as the admin APIs
+func receivable(msg message, c MessageClient, requireAdminTOTP bool) bool {
+	user := c.User()
+	if msg.LoginRequired && user == nil {
+		return false
+	}
+	if msg.AdminRequired {
+		if user == nil || !user.IsAdmin {
+			return false
+		}
+		if requireAdminTOTP && !c.MFA() {
+			return false
+		}
+	}
+	return true
+}
+
 func (app *app) Send(msg []byte, loginRequired, adminRequired bool) {
 	app.msg <- message{
 		Body:          msg,
@@ -166,3 +204,7 @@ func (app *app) Add(c MessageClient) {
 func (app *app) Remove(c MessageClient) {
 	app.remove <- c
 }
+
+func (app *app) ClientCount() int {
+	return int(atomic.LoadInt64(&app.clientCount))
+}
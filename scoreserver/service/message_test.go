@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type testClient struct {
+	user *model.User
+	mfa  bool
+}
+
+func (c *testClient) User() *model.User { return c.user }
+func (c *testClient) MFA() bool         { return c.mfa }
+func (c *testClient) Send(msg []byte)   {}
+func (c *testClient) SendHandler()      {}
+func (c *testClient) Close()            {}
+
+func TestReceivable(t *testing.T) {
+	var (
+		anonymous = &testClient{}
+		player    = &testClient{user: &model.User{}}
+		admin     = &testClient{user: &model.User{IsAdmin: true}}
+		adminMFA  = &testClient{user: &model.User{IsAdmin: true}, mfa: true}
+		public    = message{}
+		loggedIn  = message{LoginRequired: true}
+		adminOnly = message{LoginRequired: true, AdminRequired: true}
+	)
+	var testCases = []struct {
+		msg              message
+		client           MessageClient
+		requireAdminTOTP bool
+		expected         bool
+	}{
+		{public, anonymous, false, true},
+		{loggedIn, anonymous, false, false},
+		{loggedIn, player, false, true},
+		{adminOnly, player, false, false},
+		{adminOnly, admin, false, true},
+		{adminOnly, admin, true, false},
+		{adminOnly, adminMFA, true, true},
+		{loggedIn, admin, true, true},
+	}
+	for i, c := range testCases {
+		if v := receivable(c.msg, c.client, c.requireAdminTOTP); v != c.expected {
+			t.Errorf("case %d: expected %v, but %v", i, c.expected, v)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+type PrivacyApp interface {
+	ExportUserData(uid uint32) (*model.UserExport, error)
+	DeleteAccount(user *model.User, password, code string, identity *model.Identity) error
+	AdminDeleteUser(actor *model.User, uid uint32) error
+}
+
+// ExportUserData collects the profile, the team, sessions, API tokens and submissions of the user.
+// secrets (password hash, TOTP secret and the tokens themselves) are not included
+func (app *app) ExportUserData(uid uint32) (*model.UserExport, error) {
+	user, err := app.findUser(uid)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := app.repo.FindUserProfile(uid)
+	if err != nil {
+		return nil, err
+	}
+	team, err := app.GetTeam(user.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := app.repo.ListUserIdentities(uid)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := app.ListSessions(user, "")
+	if err != nil {
+		return nil, err
+	}
+	apiTokens, err := app.ListAPITokens(user)
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := app.repo.ListUserSubmissions(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.UserExport{
+		Profile:     profile,
+		Team:        team,
+		Identities:  identities,
+		Sessions:    sessions,
+		APITokens:   apiTokens,
+		Submissions: submissions,
+		ExportedAt:  time.Now().Unix(),
+	}, nil
+}
+
+// DeleteAccount deletes the user's own account after re-authentication. the second factor is required when it is enabled,
+// otherwise the password or the linked identity verified again by the provider is required.
+// identity is nil unless the user has just re-authenticated with the provider
+func (app *app) DeleteAccount(user *model.User, password, code string, identity *model.Identity) error {
+	if user.TOTPEnabled {
+		if err := app.checkSecondFactor(user, code); err != nil {
+			return err
+		}
+		return app.deleteUser(user)
+	}
+
+	if identity != nil {
+		linked, err := app.repo.FindUserByIdentity(identity.Issuer, identity.Subject)
+		if err != nil && !model.IsNotFound(err) {
+			return err
+		}
+		if err != nil || linked.ID != user.ID {
+			return ErrorMessage("the identity is not linked to your account")
+		}
+	} else if !checkPassword(user, password) {
+		return ErrorMessage("wrong password")
+	}
+	return app.deleteUser(user)
+}
+
+func (app *app) AdminDeleteUser(actor *model.User, uid uint32) error {
+	if actor.ID == uid {
+		return ErrorMessage("you can not delete yourself here. use the account page instead")
+	}
+	user, err := app.findUser(uid)
+	if err != nil {
+		return err
+	}
+	return app.deleteUser(user)
+}
+
+// deleteUser deletes the user and anonymizes what is left. the submissions stay in the team without the user,
+// so the score of the team does not change. a team left without members is renamed because
+// it is usually named after the user (solo mode, or the team created by leaving a team)
+func (app *app) deleteUser(user *model.User) error {
+	name := fmt.Sprintf("deleted-team-%d", user.TeamID)
+	if err := app.repo.DeleteUser(user.ID, user.TeamID, name, teamNameSkeleton(name)); err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("user not found")
+		}
+		return err
+	}
+
+	if user.IconPath != nil && strings.HasPrefix(*user.IconPath, IconPathPrefix) {
+		used, err := app.repo.IconInUse(*user.IconPath)
+		if err != nil {
+			return err
+		}
+		if !used {
+			if err := app.storage.Delete(strings.TrimPrefix(*user.IconPath, IconPathPrefix)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
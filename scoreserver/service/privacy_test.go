@@ -0,0 +1,163 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testdelete", "testdelete@example.com", "password", "team-testdelete", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testdelete", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := app.ExportUserData(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Profile.Email != "testdelete@example.com" || data.Team.ID != user.TeamID {
+		t.Errorf("wrong export: %v", data)
+	}
+
+	if err := app.DeleteAccount(user, "wrongpassword", "", nil); err == nil {
+		t.Error("deleted with the wrong password")
+	}
+	if err := app.DeleteAccount(user, "password", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := app.LoginUser("testdelete", "password", "", nil); err == nil {
+		t.Error("the deleted user can login")
+	}
+
+	team, err := app.GetTeam(user.TeamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Teamname == "team-testdelete" || len(team.Users) != 0 {
+		t.Errorf("the team is not anonymized: %v", team)
+	}
+
+	// the email can be used again
+	err = app.RegisterUserCreateTeam("testdelete", "testdelete@example.com", "password", "team-testdelete", "JPN", "")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteAccountCaptain(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testdeletecaptain1", "testdeletecaptain1@example.com", "password", "team-testdeletecaptain", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captain, _, err := app.LoginUser("testdeletecaptain1", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := app.GetUserTeam(captain.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.JoinUserToTeam("testdeletecaptain2", "testdeletecaptain2@example.com", "password", team.Token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, _, err := app.LoginUser("testdeletecaptain2", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.DeleteAccount(captain, "password", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	team, err = app.GetUserTeam(member.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.CaptainID == nil || *team.CaptainID != member.ID {
+		t.Errorf("the remaining member should be the captain: %v", team.CaptainID)
+	}
+	if team.Teamname != "team-testdeletecaptain" {
+		t.Errorf("the team with members should not be renamed: %s", team.Teamname)
+	}
+}
+
+func TestDeleteAccountIdentity(t *testing.T) {
+	app := newApp(t)
+
+	identity := &model.Identity{Issuer: "https://idp.example.com", Subject: "delete", Email: "testdeleteidentity@example.com", EmailVerified: true}
+	user, _, err := app.RegisterIdentityCreateTeam(identity, "testdeleteidentity", "team-testdeleteidentity", "JPN", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := &model.Identity{Issuer: "https://idp.example.com", Subject: "delete-other", Email: "testdeleteidentity@example.com"}
+	if err := app.DeleteAccount(user, "", "", other); err == nil || !IsErrorMessage(err) {
+		t.Errorf("an identity which is not linked should be rejected, err: %v", err)
+	}
+	if err := app.DeleteAccount(user, "", "", nil); err == nil || !IsErrorMessage(err) {
+		t.Errorf("deletion without re-authentication should be rejected, err: %v", err)
+	}
+	if err := app.DeleteAccount(user, "", "", identity); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := app.LoginWithIdentity(identity, nil); !model.IsNotFound(err) {
+		t.Errorf("the identity of the deleted user should be unlinked, err: %v", err)
+	}
+}
+
+func TestDeleteAccountTOTP(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testdeletetotp", "testdeletetotp@example.com", "password", "team-testdeletetotp", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testdeletetotp", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := app.EnrollTOTP(user, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(u.Query().Get("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := app.ActivateTOTP(user, totpCode(key, uint64(time.Now().Unix())/TOTPPeriod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err = app.LoginUser("testdeletetotp", "password", codes[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.DeleteAccount(user, "password", "", nil); err == nil || !IsErrorMessage(err) {
+		t.Errorf("the second factor should be required, err: %v", err)
+	}
+	if err := app.DeleteAccount(user, "password", codes[0], nil); err == nil || !IsErrorMessage(err) {
+		t.Errorf("a used recovery code should be rejected, err: %v", err)
+	}
+	if err := app.DeleteAccount(user, "", codes[1], nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := app.LoginUser("testdeletetotp", "password", codes[2], nil); err == nil {
+		t.Error("the deleted user can login")
+	}
+}
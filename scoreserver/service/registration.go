@@ -0,0 +1,105 @@
+package service
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const (
+	RegistrationClosedMessage     = "registration is closed"
+	RegistrationNotStartedMessage = "registration has not started yet"
+	InvalidInviteCodeMessage      = "invalid invite code"
+	EmailDomainNotAllowedMessage  = "registration is limited to email addresses of %s"
+)
+
+const InviteCodeMaxLength = 64
+
+type RegistrationApp interface {
+	SetRegistration(policy, inviteCode, domains string, start, end *int64) error
+}
+
+// SetRegistration updates the registration policy. domains is a comma separated list of email domains
+func (app *app) SetRegistration(policy, inviteCode, domains string, start, end *int64) error {
+	switch policy {
+	case model.RegistrationOpen, model.RegistrationClosed:
+	case model.RegistrationInvite:
+		if inviteCode == "" {
+			return ErrorMessage("invite code is required")
+		}
+	case model.RegistrationDomain:
+		if len(splitDomains(domains)) == 0 {
+			return ErrorMessage("at least one email domain is required")
+		}
+	default:
+		return ErrorMessage(fmt.Sprintf("unknown registration policy %s", policy))
+	}
+	if len(inviteCode) > InviteCodeMaxLength {
+		return ErrorMessage(fmt.Sprintf("invite code must be at most %d bytes", InviteCodeMaxLength))
+	}
+	if start != nil && end != nil && *end <= *start {
+		return ErrorMessage("registration_end must be after registration_start")
+	}
+	return app.repo.SetRegistration(policy, inviteCode, strings.Join(splitDomains(domains), ","), start, end)
+}
+
+// checkRegistration returns an error message when the policy does not allow the registration
+func checkRegistration(conf *model.Config, email, inviteCode string, t time.Time) error {
+	if conf.RegistrationStart != nil && t.Unix() < *conf.RegistrationStart {
+		return ErrorMessage(RegistrationNotStartedMessage)
+	}
+	if conf.RegistrationEnd != nil && t.Unix() >= *conf.RegistrationEnd {
+		return ErrorMessage(RegistrationClosedMessage)
+	}
+
+	switch conf.RegistrationPolicy {
+	case model.RegistrationOpen:
+		return nil
+	case model.RegistrationInvite:
+		if inviteCode == "" || subtle.ConstantTimeCompare([]byte(inviteCode), []byte(conf.RegistrationInviteCode)) != 1 {
+			return ErrorMessage(InvalidInviteCodeMessage)
+		}
+		return nil
+	case model.RegistrationDomain:
+		domains := splitDomains(conf.RegistrationDomains)
+		if !emailDomainAllowed(email, domains) {
+			return ErrorMessage(fmt.Sprintf(EmailDomainNotAllowedMessage, strings.Join(domains, ", ")))
+		}
+		return nil
+	default:
+		// closed, or unknown policies
+		return ErrorMessage(RegistrationClosedMessage)
+	}
+}
+
+// emailDomainAllowed reports whether the domain of the email is one of the domains or their subdomains
+func emailDomainAllowed(email string, domains []string) bool {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[i+1:])
+	for _, d := range domains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitDomains(domains string) []string {
+	list := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, d := range strings.Split(domains, ",") {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if _, ok := seen[d]; d == "" || ok {
+			continue
+		}
+		seen[d] = struct{}{}
+		list = append(list, d)
+	}
+	return list
+}
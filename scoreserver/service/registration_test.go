@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestCheckRegistration(t *testing.T) {
+	now := time.Unix(1000, 0)
+	past, future := int64(500), int64(1500)
+
+	var testCases = []struct {
+		conf       model.Config
+		email      string
+		inviteCode string
+		ok         bool
+	}{
+		{model.Config{RegistrationPolicy: model.RegistrationOpen}, "a@example.com", "", true},
+		{model.Config{RegistrationPolicy: model.RegistrationClosed}, "a@example.com", "", false},
+		{model.Config{RegistrationPolicy: ""}, "a@example.com", "", false},
+		{model.Config{RegistrationPolicy: model.RegistrationInvite, RegistrationInviteCode: "code"}, "a@example.com", "code", true},
+		{model.Config{RegistrationPolicy: model.RegistrationInvite, RegistrationInviteCode: "code"}, "a@example.com", "wrong", false},
+		{model.Config{RegistrationPolicy: model.RegistrationInvite, RegistrationInviteCode: ""}, "a@example.com", "", false},
+		{model.Config{RegistrationPolicy: model.RegistrationDomain, RegistrationDomains: "example.ac.jp,example.edu"}, "a@example.edu", "", true},
+		{model.Config{RegistrationPolicy: model.RegistrationDomain, RegistrationDomains: "example.ac.jp,example.edu"}, "a@cs.EXAMPLE.ac.jp", "", true},
+		{model.Config{RegistrationPolicy: model.RegistrationDomain, RegistrationDomains: "example.ac.jp,example.edu"}, "a@badexample.edu", "", false},
+		{model.Config{RegistrationPolicy: model.RegistrationDomain, RegistrationDomains: "example.ac.jp,example.edu"}, "a@example.edu@evil.com", "", false},
+		{model.Config{RegistrationPolicy: model.RegistrationOpen, RegistrationStart: &future}, "a@example.com", "", false},
+		{model.Config{RegistrationPolicy: model.RegistrationOpen, RegistrationEnd: &past}, "a@example.com", "", false},
+		{model.Config{RegistrationPolicy: model.RegistrationOpen, RegistrationStart: &past, RegistrationEnd: &future}, "a@example.com", "", true},
+	}
+	for _, c := range testCases {
+		conf := c.conf
+		if err := checkRegistration(&conf, c.email, c.inviteCode, now); (err == nil) != c.ok {
+			t.Errorf("case %+v: %v", c, err)
+		}
+	}
+}
@@ -5,15 +5,29 @@ import (
 	"github.com/google/uuid"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/mailer"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/repository"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/storage"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/webhook"
 )
 
 type App interface {
 	UserApp
+	AccountApp
+	IdentityApp
+	TOTPApp
+	APITokenApp
+	SessionApp
+	AdminApp
+	RegistrationApp
 	TeamApp
+	IconApp
 	CTFApp
 	ChallengeApp
 	MessageApp
+	PrivacyApp
+	DashboardApp
+	SubmissionLogApp
+	AuditApp
+	BackfillApp
 }
 
 type app struct {
@@ -22,15 +36,21 @@ type app struct {
 	redis   *redis.Client
 	mailer  mailer.Mailer
 	webhook webhook.Webhook
+	storage storage.Storage
+
+	frontOrigin string
 }
 
-func New(repo repository.Repository, redis *redis.Client, mailer mailer.Mailer, webhook webhook.Webhook) App {
+func New(repo repository.Repository, redis *redis.Client, mailer mailer.Mailer, webhook webhook.Webhook, storage storage.Storage, frontOrigin string) App {
 	return &app{
 		repo:       repo,
 		redis:      redis,
 		messageApp: newMessageApp(),
 		mailer:     mailer,
 		webhook:    webhook,
+		storage:    storage,
+
+		frontOrigin: frontOrigin,
 	}
 }
 
@@ -4,20 +4,56 @@ import (
 	"os"
 	"testing"
 
+	redis "github.com/go-redis/redis/v7"
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/mailer"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/repository"
 )
 
+type nopWebhook struct{}
+
+func (nopWebhook) Send(text string) error {
+	return nil
+}
+
+// testMailer passes the sent mails to the channel
+type testMailer chan string
+
+func (m testMailer) Send(to, subject, body string) error {
+	m <- body
+	return nil
+}
+
 func newApp(t *testing.T) App {
 	t.Helper()
+	app, _ := newTestApp(t, nil)
+	return app
+}
+
+func newAppWithMailer(t *testing.T, mailer mailer.Mailer) App {
+	t.Helper()
+	app, _ := newTestApp(t, mailer)
+	return app
+}
+
+// newTestApp returns the repository too, for the data which can not be made through App (challenges, ...)
+func newTestApp(t *testing.T, mailer mailer.Mailer) (App, repository.Repository) {
+	t.Helper()
 
 	dbdsn := os.Getenv("DBDSN")
 	if dbdsn == "" {
 		t.Fatal("DBDSN not set")
 	}
+	raddr := os.Getenv("REDIS")
+	if raddr == "" {
+		t.Fatal("REDIS not set")
+	}
+	redis := redis.NewClient(&redis.Options{
+		Addr: raddr,
+	})
 
-	repo, err := repository.New(dbdsn, nil)
+	repo, err := repository.New(dbdsn, redis)
 	if err != nil {
 		t.Fatal(err)
 	}
-	return New(repo, nil)
+	return New(repo, redis, mailer, nopWebhook{}, nil, "http://localhost:8080"), repo
 }
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+// SessionTouchInterval is the resolution of last_seen_at of sessions
+const SessionTouchInterval = time.Minute * 1
+
+type SessionApp interface {
+	ListSessions(user *model.User, current string) ([]*model.Token, error)
+	RevokeSession(user *model.User, id uint32) error
+	RevokeOtherSessions(user *model.User, current string) error
+	ForceLogout(uid uint32) error
+}
+
+// ListSessions returns the active sessions of the user. current is the token of the request to mark the session
+func (app *app) ListSessions(user *model.User, current string) ([]*model.Token, error) {
+	tokens, err := app.repo.ListTokens(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		t.Current = t.Token == current
+	}
+	return tokens, nil
+}
+
+func (app *app) RevokeSession(user *model.User, id uint32) error {
+	if err := app.repo.RevokeTokenByID(user.ID, id); err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("session not found")
+		}
+		return err
+	}
+	return nil
+}
+
+func (app *app) RevokeOtherSessions(user *model.User, current string) error {
+	return app.repo.RevokeTokenByUserIDExcept(user.ID, current)
+}
+
+// ForceLogout revokes every session of the user. it is for admins
+func (app *app) ForceLogout(uid uint32) error {
+	if _, err := app.repo.FindUserByID(uid); err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("user not found")
+		}
+		return err
+	}
+	return app.repo.RevokeTokenByUserID(uid)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+func TestSessions(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testsessions", "testsessions@example.com", "password", "team-testsessions", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := make([]string, 0, 3)
+	var user *model.User
+	for _, ua := range []string{"browser", "phone", "script"} {
+		u, token, err := app.LoginUser("testsessions", "password", "", &model.SessionInfo{IP: "192.0.2.1", UserAgent: ua})
+		if err != nil {
+			t.Fatal(err)
+		}
+		user = u
+		tokens = append(tokens, token)
+	}
+
+	sessions, err := app.ListSessions(user, tokens[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, but got %d", len(sessions))
+	}
+	var phone *model.Token
+	for _, s := range sessions {
+		if s.IP != "192.0.2.1" || s.LastSeenAt == nil {
+			t.Errorf("wrong session: %+v", s)
+		}
+		if s.Current != (s.UserAgent == "browser") {
+			t.Errorf("wrong current: %+v", s)
+		}
+		if s.UserAgent == "phone" {
+			phone = s
+		}
+	}
+
+	if err := app.RevokeSession(user, phone.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.GetLoginUser(tokens[1]); err == nil || !IsErrorMessage(err) {
+		t.Errorf("revoked session should not be used, err: %v", err)
+	}
+	if _, err := app.GetLoginUser(tokens[2]); err != nil {
+		t.Error(err)
+	}
+
+	if err := app.RevokeOtherSessions(user, tokens[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.GetLoginUser(tokens[2]); err == nil || !IsErrorMessage(err) {
+		t.Errorf("other sessions should be revoked, err: %v", err)
+	}
+	if _, err := app.GetLoginUser(tokens[0]); err != nil {
+		t.Error(err)
+	}
+
+	if err := app.ForceLogout(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.GetLoginUser(tokens[0]); err == nil || !IsErrorMessage(err) {
+		t.Errorf("every session should be revoked, err: %v", err)
+	}
+}
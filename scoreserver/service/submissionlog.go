@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+const (
+	SubmissionLogDefaultLimit = 50
+	SubmissionLogMaxLimit     = 500
+	// SubmissionExportMaxRows is the limit of the rows exported at once. narrow the filter to export more
+	SubmissionExportMaxRows = 100000
+)
+
+type SubmissionLogApp interface {
+	SearchSubmissions(filter *model.SubmissionFilter, cursor string, limit int) ([]*model.SubmissionLog, string, error)
+	ExportSubmissions(filter *model.SubmissionFilter) ([]*model.SubmissionLog, error)
+}
+
+// SearchSubmissions returns a page of the submissions and the cursor of the next page. the cursor is empty on the last page
+func (app *app) SearchSubmissions(filter *model.SubmissionFilter, cursor string, limit int) ([]*model.SubmissionLog, string, error) {
+	if limit <= 0 {
+		limit = SubmissionLogDefaultLimit
+	}
+	if limit > SubmissionLogMaxLimit {
+		limit = SubmissionLogMaxLimit
+	}
+	if err := checkSubmissionFilter(filter); err != nil {
+		return nil, "", err
+	}
+
+	var c *model.SubmissionCursor
+	if cursor != "" {
+		c = new(model.SubmissionCursor)
+		if _, err := fmt.Sscanf(cursor, "%d-%d", &c.SubmittedAt, &c.ID); err != nil {
+			return nil, "", ErrorMessage("invalid cursor")
+		}
+	}
+
+	// one more row to know whether the next page exists
+	logs, err := app.repo.ListSubmissionLogs(filter, c, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(logs) <= limit {
+		return logs, "", nil
+	}
+	logs = logs[:limit]
+	last := logs[limit-1]
+	return logs, fmt.Sprintf("%d-%d", last.SubmittedAt, last.ID), nil
+}
+
+func (app *app) ExportSubmissions(filter *model.SubmissionFilter) ([]*model.SubmissionLog, error) {
+	if err := checkSubmissionFilter(filter); err != nil {
+		return nil, err
+	}
+	logs, err := app.repo.ListSubmissionLogs(filter, nil, SubmissionExportMaxRows+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) > SubmissionExportMaxRows {
+		return nil, ErrorMessage(fmt.Sprintf("too many submissions. the limit is %d rows, please narrow the filter", SubmissionExportMaxRows))
+	}
+	return logs, nil
+}
+
+func checkSubmissionFilter(filter *model.SubmissionFilter) error {
+	if filter.Since != nil && filter.Until != nil && *filter.Since >= *filter.Until {
+		return ErrorMessage("since must be before until")
+	}
+	return nil
+}
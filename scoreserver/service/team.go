@@ -1,10 +1,9 @@
 package service
 
 import (
+	"fmt"
 	"time"
 
-	"golang.org/x/exp/utf8string"
-
 	"github.com/pariz/gountries"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
 )
@@ -23,6 +22,10 @@ type TeamApp interface {
 	UpdateTeamCountry(tid uint32, countryCode string) error
 
 	GetTeams() ([]*model.Team, error)
+
+	LeaveTeam(user *model.User) (*model.Team, error)
+	KickMember(captain *model.User, uid uint32) error
+	RegenerateTeamToken(user *model.User) (string, error)
 }
 
 func (app *app) GetUserTeam(uid uint32) (*model.Team, error) {
@@ -48,11 +51,14 @@ func (app *app) GetTeam(id uint32) (*model.Team, error) {
 }
 
 func (app *app) UpdateTeamName(tid uint32, newName string) error {
-	err := app.checkTeamAvailable(newName)
+	if err := app.rejectSoloMode(); err != nil {
+		return err
+	}
+	newName, err := app.checkTeamAvailable(newName)
 	if err != nil {
 		return err
 	}
-	err = app.repo.UpdateTeamName(tid, newName)
+	err = app.repo.UpdateTeamName(tid, newName, teamNameSkeleton(newName))
 	if err != nil {
 		if model.IsDuplicated(err) {
 			return ErrorMessage("teamname already used")
@@ -94,27 +100,44 @@ func (app *app) GetTeams() ([]*model.Team, error) {
 	return teams, nil
 }
 
-func (app *app) checkTeamAvailable(teamName string) error {
-	if teamName == "" {
-		return ErrorMessage("teamname is required")
+// checkTeamAvailable returns the normalized teamname when it is valid and no other team has a confusable name
+func (app *app) checkTeamAvailable(teamName string) (string, error) {
+	teamName, err := normalizeTeamName(teamName)
+	if err != nil {
+		return "", err
 	}
 
-	if len(teamName) > TeamNameMaxLength {
-		return ErrorMessage("teamname too long")
+	_, err = app.repo.FindTeamBySkeleton(teamNameSkeleton(teamName))
+	if err != nil && !model.IsNotFound(err) {
+		return "", err
 	}
-	if !utf8string.NewString(teamName).IsASCII() {
-		return ErrorMessage("teamname is not ASCII")
+	if err == nil {
+		return "", ErrorMessage("teamname already used")
 	}
 
-	_, err := app.repo.FindTeamByName(teamName)
+	return teamName, nil
+}
+
+// checkSoloTeamAvailable returns the teamname of the user in solo mode. the username goes through
+// the same teamname rules as checkTeamAvailable, because the team keeps the name when solo mode is turned off
+func (app *app) checkSoloTeamAvailable(username string) (string, error) {
+	// usernames longer than a teamname are cut, and the cut names of two users may be the same
+	if teamNameWidth(username) > TeamNameMaxLength {
+		return app.soloTeamName(username)
+	}
+	teamName, err := soloTeamBase(username)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = app.repo.FindTeamBySkeleton(teamNameSkeleton(teamName))
 	if err != nil && !model.IsNotFound(err) {
-		return err
+		return "", err
 	}
 	if err == nil {
-		return ErrorMessage("teamname already used")
+		return "", ErrorMessage("username already used")
 	}
-
-	return nil
+	return teamName, nil
 }
 
 func (app *app) validateCountryCode(countryCode string) (string, error) {
@@ -130,7 +153,7 @@ func (app *app) validateCountryCode(countryCode string) (string, error) {
 }
 
 func (app *app) createTeam(teamName, countryCode string) (uint32, error) {
-	tid, err := app.repo.CreateTeam(teamName, app.newToken(), countryCode)
+	tid, err := app.repo.CreateTeam(teamName, teamNameSkeleton(teamName), app.newToken(), countryCode)
 	if err != nil {
 		if model.IsDuplicated(err) {
 			return 0, ErrorMessage("teamname already used")
@@ -139,3 +162,146 @@ func (app *app) createTeam(teamName, countryCode string) (uint32, error) {
 	}
 	return tid, nil
 }
+
+// LeaveTeam moves the user to a new team which has only the user.
+// valid submissions stay in the old team (submissions.team_id is not changed),
+// so the old team keeps its score and the user starts from zero.
+func (app *app) LeaveTeam(user *model.User) (*model.Team, error) {
+	if err := app.rejectDuringCTF(); err != nil {
+		return nil, err
+	}
+	team, err := app.repo.FindTeamByID(user.TeamID)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("team not found")
+		}
+		return nil, err
+	}
+	if len(team.Users) <= 1 {
+		return nil, ErrorMessage("you are the only member of the team")
+	}
+	return app.moveToSoloTeam(user, team, model.MembershipLeave, &user.ID)
+}
+
+// KickMember removes the member from the captain's team. the member is moved to a new team in the same way as LeaveTeam
+func (app *app) KickMember(captain *model.User, uid uint32) error {
+	if err := app.rejectDuringCTF(); err != nil {
+		return err
+	}
+	team, err := app.repo.FindTeamByID(captain.TeamID)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("team not found")
+		}
+		return err
+	}
+	if team.CaptainID == nil || *team.CaptainID != captain.ID {
+		return ErrorMessage("only the team captain can do this")
+	}
+	if uid == captain.ID {
+		return ErrorMessage("the captain can not kick themselves. leave the team instead")
+	}
+
+	var member *model.User
+	for _, u := range team.Users {
+		if u.ID == uid {
+			member = u
+			break
+		}
+	}
+	if member == nil {
+		return ErrorMessage("the user is not a member of your team")
+	}
+
+	if _, err := app.moveToSoloTeam(member, team, model.MembershipKick, &captain.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RegenerateTeamToken replaces the invite token of the captain's team. the old token can not be used anymore
+func (app *app) RegenerateTeamToken(user *model.User) (string, error) {
+	if err := app.rejectSoloMode(); err != nil {
+		return "", err
+	}
+	team, err := app.repo.FindTeamByID(user.TeamID)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return "", ErrorMessage("team not found")
+		}
+		return "", err
+	}
+	if team.CaptainID == nil || *team.CaptainID != user.ID {
+		return "", ErrorMessage("only the team captain can do this")
+	}
+
+	token := app.newToken()
+	if err := app.repo.UpdateTeamToken(team.ID, token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// rejectSoloMode returns an error message in solo mode, for the operations about team members
+func (app *app) rejectSoloMode() error {
+	conf, err := app.GetConfig()
+	if err != nil {
+		return err
+	}
+	if conf.SoloMode {
+		return ErrorMessage(SoloModeMessage)
+	}
+	return nil
+}
+
+// rejectDuringCTF returns an error message while the CTF is running. a user who leaves the team
+// starts a new team without solves, so the user could submit the flags of the old team again
+func (app *app) rejectDuringCTF() error {
+	running, err := app.CTFNowRunning(time.Now())
+	if err != nil {
+		return err
+	}
+	if running {
+		return ErrorMessage("you can not leave or kick members of the team during the CTF")
+	}
+	return nil
+}
+
+func (app *app) moveToSoloTeam(user *model.User, oldTeam *model.Team, action string, actorID *uint32) (*model.Team, error) {
+	name, err := app.soloTeamName(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	tid, err := app.repo.MoveToNewTeam(user.ID, oldTeam.ID, action, actorID, name, teamNameSkeleton(name), app.newToken(), oldTeam.CountryCode)
+	if err != nil {
+		if model.IsDuplicated(err) {
+			return nil, ErrorMessage("teamname already used")
+		}
+		if model.IsNotFound(err) {
+			return nil, ErrorMessage("the user is not a member of the team")
+		}
+		return nil, err
+	}
+	return app.repo.FindTeamByID(tid)
+}
+
+// soloTeamName returns an unused teamname based on the username
+func (app *app) soloTeamName(username string) (string, error) {
+	base, err := soloTeamBase(username)
+	if err != nil {
+		return "", err
+	}
+	name := base
+	for i := 2; ; i++ {
+		_, err := app.repo.FindTeamBySkeleton(teamNameSkeleton(name))
+		if model.IsNotFound(err) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		suffix := fmt.Sprintf("-%d", i)
+		name = truncateTeamName(base, TeamNameMaxLength-len(suffix)) + suffix
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"testing"
+	"time"
 )
 
 func TestValidateContryCode(t *testing.T) {
@@ -29,3 +30,156 @@ func TestValidateContryCode(t *testing.T) {
 		}
 	}
 }
+
+func TestLeaveTeam(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testleave1", "testleave1@example.com", "password", "team-testleave", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captain, _, err := app.LoginUser("testleave1", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setCTFRunning(t, app, false)
+	_, err = app.LeaveTeam(captain)
+	if err == nil {
+		t.Error("the only member should not be able to leave the team")
+	}
+
+	team, err := app.GetUserTeam(captain.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.JoinUserToTeam("testleave2", "testleave2@example.com", "password", team.Token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, _, err := app.LoginUser("testleave2", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setCTFRunning(t, app, true)
+	if _, err := app.LeaveTeam(member); err == nil || !IsErrorMessage(err) {
+		t.Errorf("leaving the team should be rejected during the CTF, err: %v", err)
+	}
+	setCTFRunning(t, app, false)
+
+	newTeam, err := app.LeaveTeam(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newTeam.ID == team.ID || newTeam.Teamname != "testleave2" {
+		t.Errorf("unexpected new team: %v", newTeam)
+	}
+	if newTeam.CaptainID == nil || *newTeam.CaptainID != member.ID {
+		t.Error("the user should be the captain of the new team")
+	}
+
+	// the captain leaves and the earliest remaining member becomes the captain
+	err = app.JoinUserToTeam("testleave3", "testleave3@example.com", "password", team.Token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.LeaveTeam(captain); err != nil {
+		t.Fatal(err)
+	}
+	team, err = app.GetTeam(team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(team.Users) != 1 || team.CaptainID == nil || *team.CaptainID != team.Users[0].ID {
+		t.Errorf("the remaining member should be the captain: %+v", team)
+	}
+}
+
+func TestFillTeamCaptains(t *testing.T) {
+	app, repo := newTestApp(t, nil)
+
+	// a team registered before captains were introduced
+	tid, err := repo.CreateTeam("team-testfillcaptain", "team-testfillcaptain", "testfillcaptain-token", "JPN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, err := repo.RegisterUser("testfillcaptain", "testfillcaptain@example.com", "", tid, 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.Backfill(); err != nil {
+		t.Fatal(err)
+	}
+	team, err := app.GetTeam(tid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.CaptainID == nil || *team.CaptainID != uid {
+		t.Errorf("the member should be the captain: %v", team.CaptainID)
+	}
+}
+
+// setCTFRunning sets the CTF period so that the CTF is running now, or has already finished
+func setCTFRunning(t *testing.T, app App, running bool) {
+	t.Helper()
+	now := time.Now().Unix()
+	end := now - 1
+	if running {
+		end = now + 3600
+	}
+	if err := app.SetStartAt(now - 3600); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SetEndAt(end); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKickMember(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testkick1", "testkick1@example.com", "password", "team-testkick", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captain, _, err := app.LoginUser("testkick1", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := app.GetUserTeam(captain.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.JoinUserToTeam("testkick2", "testkick2@example.com", "password", team.Token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, _, err := app.LoginUser("testkick2", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setCTFRunning(t, app, false)
+	if err := app.KickMember(member, captain.ID); err == nil {
+		t.Error("only the captain can kick members")
+	}
+	if err := app.KickMember(captain, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	memberTeam, err := app.GetUserTeam(member.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memberTeam.ID == team.ID {
+		t.Error("kicked member is still in the team")
+	}
+
+	token, err := app.RegenerateTeamToken(captain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == team.Token {
+		t.Error("token is not regenerated")
+	}
+}
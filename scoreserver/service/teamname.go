@@ -0,0 +1,140 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// TeamNameMaxRunes is the limit of teams.teamname
+const TeamNameMaxRunes = 64
+
+// teamNameMaxMarks is the number of combining marks allowed on a character
+const teamNameMaxMarks = 2
+
+// invisibleRunes are letters and symbols which are rendered as blank
+var invisibleRunes = map[rune]struct{}{
+	'\u115f': {}, // HANGUL CHOSEONG FILLER
+	'\u1160': {}, // HANGUL JUNGSEONG FILLER
+	'\u3164': {}, // HANGUL FILLER
+	'\uffa0': {}, // HALFWIDTH HANGUL FILLER
+	'\u2800': {}, // BRAILLE PATTERN BLANK
+}
+
+// normalizeTeamName returns the NFKC normalized teamname with the spaces collapsed.
+// ASCII teamnames are kept as they are, as the teamnames registered before non-ASCII teamnames were allowed.
+// it rejects control, format (bidi, zero-width, ...) and invisible characters,
+// and checks the display width instead of the length in bytes
+func normalizeTeamName(teamName string) (string, error) {
+	if !utf8.ValidString(teamName) {
+		return "", ErrorMessage("teamname is not valid UTF-8")
+	}
+	if !isASCII(teamName) {
+		teamName = strings.Join(strings.Fields(norm.NFKC.String(teamName)), " ")
+	}
+	if strings.TrimSpace(teamName) == "" {
+		return "", ErrorMessage("teamname is required")
+	}
+
+	marks := 0
+	for i, r := range teamName {
+		if _, ok := invisibleRunes[r]; ok {
+			return "", ErrorMessage(fmt.Sprintf("teamname contains an invisible character U+%04X", r))
+		}
+		if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) {
+			marks++
+			if i == 0 || marks > teamNameMaxMarks {
+				return "", ErrorMessage("teamname contains too many combining marks")
+			}
+			continue
+		}
+		marks = 0
+		if r == ' ' || unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsNumber(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		// control, format, private use, unassigned and the other separators
+		return "", ErrorMessage(fmt.Sprintf("teamname contains an invalid character U+%04X", r))
+	}
+
+	if utf8.RuneCountInString(teamName) > TeamNameMaxRunes || teamNameWidth(teamName) > TeamNameMaxLength {
+		return "", ErrorMessage(fmt.Sprintf("teamname too long. the limit is %d columns (wide characters count as two)", TeamNameMaxLength))
+	}
+	return teamName, nil
+}
+
+// soloTeamBase returns the teamname of a solo user. a username can be longer than a teamname, so it is cut first
+func soloTeamBase(username string) (string, error) {
+	return normalizeTeamName(truncateTeamName(username, TeamNameMaxLength))
+}
+
+// isASCII reports whether the string has only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// teamNameWidth returns the display width. East Asian wide characters are two columns and marks are zero
+func teamNameWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		switch {
+		case unicode.IsMark(r):
+		case width.LookupRune(r).Kind() == width.EastAsianWide, width.LookupRune(r).Kind() == width.EastAsianFullwidth:
+			w += 2
+		default:
+			w++
+		}
+	}
+	return w
+}
+
+// truncateTeamName cuts the teamname to the display width without splitting a character
+func truncateTeamName(teamName string, maxWidth int) string {
+	w := 0
+	for i, r := range teamName {
+		w += teamNameWidth(string(r))
+		if w > maxWidth {
+			return strings.TrimRight(teamName[:i], " ")
+		}
+	}
+	return teamName
+}
+
+// teamNameSkeleton returns the key to find teamnames which look the same, like "Team" and "Тeam" (Cyrillic Te).
+// it is a small subset of the skeleton of UTS #39: confusable letters of the other scripts are mapped to ASCII.
+// the case is kept, so an ASCII teamname is its own skeleton and ASCII teamnames collide only when they are the same
+func teamNameSkeleton(teamName string) string {
+	s := norm.NFKC.String(teamName)
+	var b strings.Builder
+	for _, r := range s {
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFKC.String(b.String())
+}
+
+// confusables maps non-ASCII letters to the ASCII letters which look the same. fullwidth forms are handled by NFKC
+var confusables = map[rune]rune{
+	// Cyrillic
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X',
+	'Ѕ': 'S', 'І': 'I', 'Ј': 'J', 'Ԁ': 'D', 'Ԛ': 'Q', 'Ԝ': 'W', 'Ү': 'Y', 'Ӏ': 'I',
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x',
+	'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ү': 'y', 'һ': 'h', 'ӏ': 'l',
+	// Greek
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	'α': 'a', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y',
+	// Armenian
+	'օ': 'o', 'ս': 'u', 'ց': 'g', 'հ': 'h', 'ո': 'n', 'զ': 'q',
+	// Latin
+	'ı': 'i', 'ȷ': 'j', 'ɡ': 'g', 'ʏ': 'y', 'ʜ': 'h', 'ɪ': 'i', 'ʟ': 'l', 'ɴ': 'n', 'ʀ': 'r', 'ᴀ': 'a', 'ᴄ': 'c', 'ᴏ': 'o', 'ᴜ': 'u', 'ᴠ': 'v', 'ᴡ': 'w', 'ᴢ': 'z',
+}
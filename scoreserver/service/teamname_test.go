@@ -0,0 +1,127 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTeamName(t *testing.T) {
+	var testCases = []struct {
+		input  string
+		output string
+		ok     bool
+	}{
+		{"zer0pts", "zer0pts", true},
+		// ASCII teamnames are kept as they are
+		{"  Team   A ", "  Team   A ", true},
+		{"TEAM a", "TEAM a", true},
+		{"ｚｅｒｏ\u3000ｐｔｓ", "zero pts", true},
+		{" 東京  大学 ", "東京 大学", true},
+		{"東京大学", "東京大学", true},
+		{"한국팀", "한국팀", true},
+		{"ｶﾞｯｺｳ", "ガッコウ", true},
+		{"", "", false},
+		{" \u3000 ", "", false},
+		{"   ", "", false},
+		{"team\tname", "", false},
+		{"team\u200bname", "", false},
+		{"team\u202ename", "", false},
+		{"team\u2066name\u2069", "", false},
+		{"team\x00", "", false},
+		{"\u3164", "", false},
+		{"a\u0301\u0301\u0301\u0301", "", false},
+		{"\u0301a", "", false},
+		{"01234567890123456789012345678901", "01234567890123456789012345678901", true},
+		{"01234567890123456789012345678901x", "", false},
+		{"あいうえおかきくけこさしすせそた", "あいうえおかきくけこさしすせそた", true},
+		{"あいうえおかきくけこさしすせそたち", "", false},
+		{"\xff", "", false},
+	}
+	for _, c := range testCases {
+		output, err := normalizeTeamName(c.input)
+		if c.ok != (err == nil) {
+			t.Errorf("case %q, err: %v", c.input, err)
+		}
+		if output != c.output {
+			t.Errorf("case %q: expected %q, but got %q", c.input, c.output, output)
+		}
+	}
+}
+
+func TestTeamNameSkeleton(t *testing.T) {
+	var collide = [][2]string{
+		{"Team", "Теam"},
+		{"paypal", "раураl"},
+		{"zer0pts", "ｚｅｒ０ｐｔｓ"},
+		{"HELLO", "ΗΕLLΟ"},
+	}
+	for _, c := range collide {
+		if teamNameSkeleton(c[0]) != teamNameSkeleton(c[1]) {
+			t.Errorf("%q and %q should collide", c[0], c[1])
+		}
+	}
+
+	var distinct = [][2]string{
+		{"team1", "teaml"},
+		{"zer0pts", "zeropts"},
+		{"がっこう", "かっこう"},
+		{"Team A", "TeamA"},
+		{"Team", "team"},
+		{"zer0pts", "ZER0PTS"},
+		{"Team A", "Team A "},
+	}
+	for _, c := range distinct {
+		if teamNameSkeleton(c[0]) == teamNameSkeleton(c[1]) {
+			t.Errorf("%q and %q should not collide", c[0], c[1])
+		}
+	}
+}
+
+// TestTeamNameSkeletonASCII checks that the ASCII teamnames registered before the skeletons are their own skeletons,
+// so that the backfill does not find collisions which the old exact comparison did not have
+func TestTeamNameSkeletonASCII(t *testing.T) {
+	for _, name := range []string{"zer0pts", "Zer0pts", "TSG", "tsg", "  Team   A ", "team_a-1"} {
+		if skeleton := teamNameSkeleton(name); skeleton != name {
+			t.Errorf("the skeleton of %q should be itself, but got %q", name, skeleton)
+		}
+	}
+}
+
+func TestTruncateTeamName(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		maxWidth int
+		output   string
+	}{
+		{"zer0pts", 30, "zer0pts"},
+		{"01234567890123456789012345678901", 30, "012345678901234567890123456789"},
+		{"あいうえお", 5, "あい"},
+		{"team a", 5, "team"},
+		{"a\u0301bc", 2, "a\u0301b"},
+	}
+	for _, c := range testCases {
+		output := truncateTeamName(c.input, c.maxWidth)
+		if output != c.output {
+			t.Errorf("case %q (%d): expected %q, but got %q", c.input, c.maxWidth, c.output, output)
+		}
+		if teamNameWidth(output) > c.maxWidth {
+			t.Errorf("case %q (%d): %q is too wide", c.input, c.maxWidth, output)
+		}
+	}
+}
+
+func TestSoloTeamBase(t *testing.T) {
+	username := strings.Repeat("a", 40)
+	teamName, err := soloTeamBase(username)
+	if err != nil {
+		t.Fatalf("a username of 40 characters should be a valid solo teamname: %v", err)
+	}
+	if teamName != strings.Repeat("a", TeamNameMaxLength) {
+		t.Errorf("expected the first %d characters, but got %q", TeamNameMaxLength, teamName)
+	}
+
+	teamName, err = soloTeamBase("solo_user-1")
+	if err != nil || teamName != "solo_user-1" {
+		t.Errorf("a short username should be kept, but got %q, %v", teamName, err)
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
+)
+
+// TOTP parameters follow RFC 6238 defaults, which every authenticator app supports
+const (
+	TOTPPeriod = 30
+	TOTPDigits = 6
+	// TOTPSkew is how many steps before and after the current one are accepted
+	TOTPSkew = 1
+
+	RecoveryCodeCount = 10
+
+	TOTPRequiredMessage      = "two-factor code is required"
+	AdminTOTPRequiredMessage = "admin requires login with two-factor authentication"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TOTPApp interface {
+	EnrollTOTP(user *model.User, password string) (string, error)
+	ActivateTOTP(user *model.User, code string) ([]string, error)
+	DisableTOTP(user *model.User, password, code string) error
+	CheckAdminSession(token string) error
+	IsMFASession(token string) (bool, error)
+}
+
+// EnrollTOTP generates a new secret and returns its provisioning URI (otpauth://).
+// the secret is not used for login until it is activated by ActivateTOTP
+func (app *app) EnrollTOTP(user *model.User, password string) (string, error) {
+	if !checkPassword(user, password) {
+		return "", ErrorMessage("wrong password")
+	}
+	if user.TOTPEnabled {
+		return "", ErrorMessage("two-factor authentication is already enabled")
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	secret := totpEncoding.EncodeToString(key)
+	if err := app.repo.SetTOTPSecret(user.ID, &secret); err != nil {
+		return "", err
+	}
+
+	issuer := "zer0ptsctfd"
+	if conf, err := app.GetConfig(); err == nil && conf.CTFName != "" {
+		issuer = conf.CTFName
+	}
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	q.Set("period", fmt.Sprintf("%d", TOTPPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(user.Username)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, q.Encode()), nil
+}
+
+// ActivateTOTP enables the enrolled secret after checking a code from the authenticator,
+// and returns the recovery codes. they are shown only once
+func (app *app) ActivateTOTP(user *model.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrorMessage("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrorMessage("two-factor authentication is not enrolled")
+	}
+	if err := app.checkTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := app.newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.repo.EnableTOTP(user.ID, true); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (app *app) DisableTOTP(user *model.User, password, code string) error {
+	if !checkPassword(user, password) {
+		return ErrorMessage("wrong password")
+	}
+	if !user.TOTPEnabled {
+		return ErrorMessage("two-factor authentication is not enabled")
+	}
+	if err := app.checkSecondFactor(user, code); err != nil {
+		return err
+	}
+	if err := app.repo.SetTOTPSecret(user.ID, nil); err != nil {
+		return err
+	}
+	return app.repo.ReplaceRecoveryCodes(user.ID, nil)
+}
+
+// CheckAdminSession rejects sessions logged in without the second factor when the config requires it for admins
+func (app *app) CheckAdminSession(token string) error {
+	conf, err := app.GetConfig()
+	if err != nil {
+		return err
+	}
+	if !conf.RequireAdminTOTP {
+		return nil
+	}
+
+	t, err := app.repo.FindToken(token)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("invalid token")
+		}
+		return err
+	}
+	if !t.MFA {
+		return ErrorMessage(AdminTOTPRequiredMessage)
+	}
+	return nil
+}
+
+// IsMFASession returns whether the session was created with the second factor. unknown sessions are not
+func (app *app) IsMFASession(token string) (bool, error) {
+	t, err := app.repo.FindToken(token)
+	if err != nil {
+		if model.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return t.MFA, nil
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code
+func (app *app) checkSecondFactor(user *model.User, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrorMessage(TOTPRequiredMessage)
+	}
+	if len(code) == TOTPDigits {
+		return app.checkTOTP(user, code)
+	}
+
+	ok, err := app.repo.UseRecoveryCode(user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorMessage("wrong two-factor code")
+	}
+	return nil
+}
+
+func (app *app) checkTOTP(user *model.User, code string) error {
+	if user.TOTPSecret == nil {
+		return ErrorMessage("two-factor authentication is not enrolled")
+	}
+	key, err := totpEncoding.DecodeString(*user.TOTPSecret)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	now := uint64(time.Now().Unix()) / TOTPPeriod
+	for step := now - TOTPSkew; step <= now+TOTPSkew; step++ {
+		if step <= user.TOTPLastStep || !hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			continue
+		}
+		ok, err := app.repo.UpdateTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return ErrorMessage("wrong two-factor code")
+}
+
+func (app *app) newRecoveryCodes(uid uint32) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	if err := app.repo.ReplaceRecoveryCodes(uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// recovery codes are random enough, so sha256 is sufficient to keep them from a leaked database
+func hashRecoveryCode(code string) string {
+	h := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(h[:])
+}
+
+// totpCode computes the code of the time step (RFC 4226 dynamic truncation)
+func totpCode(key []byte, step uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, step)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// test vectors of RFC 6238 (SHA1), truncated to 6 digits
+	key := []byte("12345678901234567890")
+	var testCases = []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range testCases {
+		if code := totpCode(key, uint64(c.time)/TOTPPeriod); code != c.code {
+			t.Errorf("time %d: expected %s, but got %s", c.time, c.code, code)
+		}
+	}
+}
+
+func TestTOTPLogin(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testtotp", "testtotp@example.com", "password", "team-testtotp", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testtotp", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := app.EnrollTOTP(user, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(u.Query().Get("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := totpCode(key, uint64(time.Now().Unix())/TOTPPeriod)
+
+	user, _, err = app.LoginUser("testtotp", "password", "", nil)
+	if err != nil {
+		t.Fatal("TOTP must not be required before activation")
+	}
+	codes, err := app.ActivateTOTP(user, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Errorf("expected %d recovery codes, but got %d", RecoveryCodeCount, len(codes))
+	}
+
+	if _, _, err := app.LoginUser("testtotp", "password", "", nil); err == nil {
+		t.Error("login without the code should fail")
+	}
+	if _, _, err := app.LoginUser("testtotp", "password", code, nil); err == nil {
+		t.Error("a used code should not be accepted again")
+	}
+	if _, _, err := app.LoginUser("testtotp", "password", codes[0], nil); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := app.LoginUser("testtotp", "password", codes[0], nil); err == nil {
+		t.Error("a used recovery code should not be accepted again")
+	}
+}
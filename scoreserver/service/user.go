@@ -2,11 +2,12 @@ package service
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"net/url"
 	"time"
 
-	"github.com/google/uuid"
 	"gitlab.com/zer0pts/zer0ptsctfd/scoreserver/model"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,17 +15,25 @@ import (
 const UsernameMaxLength = 64
 const TokenLimit = time.Hour * 24 * 7
 const PasswordResetTokenLimit = time.Hour * 1
+const PasswordResetMailInterval = time.Minute * 1
+const PasswordResetIPLimit = 10
+const PasswordResetIPWindow = time.Hour * 1
+const EmailVerificationTokenLimit = time.Hour * 24
+const VerificationMailInterval = time.Minute * 1
 
 type UserApp interface {
-	JoinUserToTeam(username, email, password, token string) error
-	RegisterUserCreateTeam(username, email, password, teamName, countryCode string) error
-	LoginUser(username, password string) (*model.User, string, error)
+	JoinUserToTeam(username, email, password, token, inviteCode string) error
+	RegisterUserCreateTeam(username, email, password, teamName, countryCode, inviteCode string) error
+	LoginUser(username, password, code string, info *model.SessionInfo) (*model.User, string, error)
 	LogoutUser(uid uint32) error
 	LogoutUserByToken(token string) error
 	GetLoginUser(token string) (*model.User, error)
 
-	IssuePasswordResetToken(email string) error
+	IssuePasswordResetToken(email string, info *model.SessionInfo) error
 	ResetPassword(token, password string) error
+
+	VerifyEmail(token string) error
+	ResendVerificationEmail(user *model.User) error
 }
 
 func (app *app) checkUserAvailable(username, email, password string) error {
@@ -38,6 +47,16 @@ func (app *app) checkUserAvailable(username, email, password string) error {
 		return ErrorMessage("password is required")
 	}
 
+	if err := app.checkUsernameAvailable(username); err != nil {
+		return err
+	}
+	if err := app.checkEmailAvailable(email); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (app *app) checkUsernameAvailable(username string) error {
 	//(username must be matched to ^[0-9A-Za-z_-]{1, 32}$
 	if len(username) > UsernameMaxLength {
 		return ErrorMessage("username must follow the regex: ^[0-9A-Za-z_-]{1, 32}$")
@@ -54,8 +73,11 @@ func (app *app) checkUserAvailable(username, email, password string) error {
 	if err == nil {
 		return ErrorMessage("username already used")
 	}
+	return nil
+}
 
-	_, err = app.repo.FindUserByEmail(email)
+func (app *app) checkEmailAvailable(email string) error {
+	_, err := app.repo.FindUserByEmail(email)
 	if err != nil && !model.IsNotFound(err) {
 		return err
 	}
@@ -65,89 +87,207 @@ func (app *app) checkUserAvailable(username, email, password string) error {
 	return nil
 }
 
-func (app *app) registerUser(username, email, password string, tid uint32) (uint32, error) {
+// hashPassword hashes sha256(password) with bcrypt. sha256 is for the 72 bytes limit of bcrypt
+func hashPassword(password string) (string, error) {
 	sha256password := sha256.Sum256([]byte(password))
 	passwordHash, err := bcrypt.GenerateFromPassword(sha256password[:], bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	return string(passwordHash), nil
+}
+
+func checkPassword(user *model.User, password string) bool {
+	sha256password := sha256.Sum256([]byte(password))
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), sha256password[:]) == nil
+}
+
+// registerUser adds the user to the team. when emailVerified is false and the verification is enabled,
+// the verification token is created with the user and the mail is sent after the registration
+func (app *app) registerUser(conf *model.Config, username, email, password string, tid uint32, maxTeamSize int, emailVerified bool) (uint32, error) {
+	passwordHash, err := hashPassword(password)
 	if err != nil {
 		return 0, err
 	}
 
-	uid, err := app.repo.RegisterUser(username, email, string(passwordHash), tid)
+	var token string
+	var verification *model.EmailVerificationToken
+	if !emailVerified && conf.EmailVerification {
+		token, verification = app.newEmailVerificationToken()
+	}
+	uid, err := app.repo.RegisterUser(username, email, passwordHash, tid, maxTeamSize, emailVerified, verification)
 	if err != nil {
 		if model.IsDuplicated(err) {
 			return 0, ErrorMessage("username already used")
 		}
+		if model.IsTeamFull(err) {
+			return 0, ErrorMessage("the team is full")
+		}
 		return 0, err
 	}
+
+	if verification != nil {
+		// the user is already registered. the mail can be sent again from the account page
+		if _, err := app.repo.LockVerificationMail(uid, VerificationMailInterval); err != nil {
+			log.Println(err)
+		}
+		app.mailVerificationLink(email, token)
+	}
 	return uid, nil
 }
 
-func (app *app) JoinUserToTeam(username, email, password, token string) error {
+func (app *app) JoinUserToTeam(username, email, password, token, inviteCode string) error {
+	_, err := app.joinTeam(username, email, password, token, inviteCode, false)
+	return err
+}
+
+// joinTeam registers the user to the team of the token when the registration policy allows.
+// when emailVerified is false and the verification is enabled, a verification mail is sent
+func (app *app) joinTeam(username, email, password, token, inviteCode string, emailVerified bool) (uint32, error) {
+	conf, err := app.GetConfig()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkRegistration(conf, email, inviteCode, time.Now()); err != nil {
+		return 0, err
+	}
+	if conf.SoloMode {
+		return 0, ErrorMessage(SoloModeMessage)
+	}
+
 	t, err := app.repo.FindTeamByToken(token)
 	if err != nil {
 		if model.IsNotFound(err) {
-			return ErrorMessage("invalid token")
+			return 0, ErrorMessage("invalid token")
 		}
-		return err
+		return 0, err
 	}
 
 	err = app.checkUserAvailable(username, email, password)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	_, err = app.registerUser(username, email, password, t.ID)
+	uid, err := app.registerUser(conf, username, email, password, t.ID, conf.MaxTeamSize, emailVerified)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	if err := app.repo.AddMembershipHistory(uid, t.ID, model.MembershipJoin, &uid); err != nil {
+		return 0, err
+	}
+	return uid, nil
+}
 
-	return nil
+func (app *app) RegisterUserCreateTeam(username, email, password, teamName, countryCode, inviteCode string) error {
+	_, err := app.createTeamWithUser(username, email, password, teamName, countryCode, inviteCode, false)
+	return err
 }
 
-func (app *app) RegisterUserCreateTeam(username, email, password, teamName, countryCode string) error {
-	err := app.checkUserAvailable(username, email, password)
+// createTeamWithUser registers the user as the captain of a new team.
+// in solo mode teamName is ignored and the team is named after the user
+func (app *app) createTeamWithUser(username, email, password, teamName, countryCode, inviteCode string, emailVerified bool) (uint32, error) {
+	conf, err := app.GetConfig()
 	if err != nil {
-		return err
+		return 0, err
 	}
-	err = app.checkTeamAvailable(teamName)
+	if err := checkRegistration(conf, email, inviteCode, time.Now()); err != nil {
+		return 0, err
+	}
+	err = app.checkUserAvailable(username, email, password)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if conf.SoloMode {
+		teamName, err = app.checkSoloTeamAvailable(username)
+	} else {
+		teamName, err = app.checkTeamAvailable(teamName)
+	}
+	if err != nil {
+		return 0, err
 	}
 	code, err := app.validateCountryCode(countryCode)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	tid, err := app.createTeam(teamName, code)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = app.registerUser(username, email, password, tid)
+	uid, err := app.registerUser(conf, username, email, password, tid, 0, emailVerified)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	if err := app.repo.SetTeamCaptain(tid, &uid); err != nil {
+		return 0, err
+	}
+	if err := app.repo.AddMembershipHistory(uid, tid, model.MembershipCreate, &uid); err != nil {
+		return 0, err
+	}
+	return uid, nil
 }
 
-func (app *app) LoginUser(username, password string) (*model.User, string, error) {
+// LoginUser checks the password, and the second factor (code) when the user enabled TOTP
+func (app *app) LoginUser(username, password, code string, info *model.SessionInfo) (*model.User, string, error) {
+	if err := app.checkLoginLock(username, info); err != nil {
+		return nil, "", err
+	}
+
 	user, err := app.repo.FindUserByName(username)
 	if err != nil {
-		return nil, "", ErrorMessage("wrong username")
+		if !model.IsNotFound(err) {
+			return nil, "", err
+		}
+		checkPassword(&model.User{PasswordHash: dummyPasswordHash()}, password)
+		return nil, "", app.loginFailed(username, info, ErrorMessage(LoginFailedMessage))
 	}
 
-	sha256password := sha256.Sum256([]byte(password))
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), sha256password[:]); err != nil {
-		return nil, "", ErrorMessage("wrong password")
+	if !checkPassword(user, password) {
+		return nil, "", app.loginFailed(username, info, ErrorMessage(LoginFailedMessage))
 	}
-
-	token := app.newToken()
-	if err := app.repo.NewToken(user.ID, token, uint64(time.Now().Add(TokenLimit).Unix())); err != nil {
+	if err := checkBanned(user); err != nil {
+		return nil, "", err
+	}
+	if user.TOTPEnabled {
+		if err := app.checkSecondFactor(user, code); err != nil {
+			// asking for the code is not a failure, but a wrong code is
+			if err.Error() == TOTPRequiredMessage {
+				return nil, "", err
+			}
+			if IsErrorMessage(err) {
+				return nil, "", app.loginFailed(username, info, err)
+			}
+			return nil, "", err
+		}
+	}
+	if err := app.repo.ResetLoginFailure(accountLoginKey(username)); err != nil {
 		return nil, "", err
 	}
 
+	token, err := app.issueLoginToken(user.ID, user.TOTPEnabled, info)
+	if err != nil {
+		return nil, "", err
+	}
 	return user, token, nil
 }
 
+// checkBanned returns the reason as an error message when the user is banned
+func checkBanned(user *model.User) error {
+	if user.BanReason != nil {
+		return ErrorMessage(fmt.Sprintf(BannedMessage, *user.BanReason))
+	}
+	return nil
+}
+
+// issueLoginToken creates a session. mfa records that the second factor was used
+func (app *app) issueLoginToken(uid uint32, mfa bool, info *model.SessionInfo) (string, error) {
+	token := app.newToken()
+	if err := app.repo.NewToken(uid, token, uint64(time.Now().Add(TokenLimit).Unix()), mfa, info); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
 func (app *app) LogoutUser(uid uint32) error {
 	return app.repo.RevokeTokenByUserID(uid)
 }
@@ -163,25 +303,60 @@ func (app *app) GetLoginUser(token string) (*model.User, error) {
 		}
 		return nil, err
 	}
+	if err := checkBanned(user); err != nil {
+		return nil, err
+	}
+	if err := app.repo.TouchToken(token, SessionTouchInterval); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
-func (app *app) IssuePasswordResetToken(email string) error {
+
+// IssuePasswordResetToken mails a reset link when the email is registered. it returns nil also for unknown
+// or recently requested emails, so that the response does not tell whether the email is registered
+func (app *app) IssuePasswordResetToken(email string, info *model.SessionInfo) error {
+	if info != nil && info.IP != "" {
+		cnt, err := app.repo.IncrementPasswordResetRequest(info.IP, PasswordResetIPWindow)
+		if err != nil {
+			return err
+		}
+		if cnt > PasswordResetIPLimit {
+			return ErrorMessage("too many password reset requests. please try again later")
+		}
+	}
+	if email == "" {
+		return ErrorMessage("email is required")
+	}
+
+	ok, err := app.repo.LockPasswordResetMail(email, PasswordResetMailInterval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
 	user, err := app.repo.FindUserByEmail(email)
 	if err != nil {
 		if model.IsNotFound(err) {
-			return ErrorMessage("invalid email")
+			return nil
 		}
 		return err
 	}
 
-	token := uuid.New().String()
-	err = app.repo.NewPasswordResetToken(user.ID, token, uint64(time.Now().Add(PasswordResetTokenLimit).Unix()))
+	if err := app.repo.RevokePasswordResetTokenByUserID(user.ID); err != nil {
+		return err
+	}
+	token := app.newToken()
+	err = app.repo.NewPasswordResetToken(user.ID, hashPasswordResetToken(token), uint64(time.Now().Add(PasswordResetTokenLimit).Unix()))
 	if err != nil {
 		return err
 	}
 
+	link := fmt.Sprintf("%s/#/reset?token=%s", app.frontOrigin, url.QueryEscape(token))
+	body := fmt.Sprintf("please open the following link to reset your password:\n%s\n\nthe link expires in %v. if you did not request it, you can ignore this email", link, PasswordResetTokenLimit)
 	go func() {
-		err := app.mailer.Send(email, "password reset token", fmt.Sprintf("your password reset token is: %s", token))
+		err := app.mailer.Send(user.Email, "password reset", body)
 		if err != nil {
 			log.Println(err)
 		}
@@ -194,7 +369,7 @@ func (app *app) ResetPassword(token, password string) error {
 		return ErrorMessage("password is required")
 	}
 
-	user, err := app.repo.FindUserByPasswordResetToken(token)
+	user, err := app.repo.FindUserByPasswordResetToken(hashPasswordResetToken(token))
 	if err != nil {
 		if model.IsNotFound(err) {
 			return ErrorMessage("invalid token")
@@ -202,13 +377,12 @@ func (app *app) ResetPassword(token, password string) error {
 		return err
 	}
 
-	sha256password := sha256.Sum256([]byte(password))
-	passwordHash, err := bcrypt.GenerateFromPassword(sha256password[:], bcrypt.DefaultCost)
+	passwordHash, err := hashPassword(password)
 	if err != nil {
-		return fmt.Errorf("%w", err)
+		return err
 	}
 
-	err = app.repo.UpdateUserPassword(user.ID, string(passwordHash))
+	err = app.repo.UpdateUserPassword(user.ID, passwordHash)
 	if err != nil {
 		return err
 	}
@@ -218,5 +392,81 @@ func (app *app) ResetPassword(token, password string) error {
 		return err
 	}
 
+	// the password may have been reset because the account was taken over
+	return app.repo.RevokeTokenByUserID(user.ID)
+}
+
+// hashPasswordResetToken returns the value stored in password_reset_tokens.token_hash
+func hashPasswordResetToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func (app *app) VerifyEmail(token string) error {
+	user, err := app.repo.FindUserByEmailVerificationToken(hashEmailVerificationToken(token))
+	if err != nil {
+		if model.IsNotFound(err) {
+			return ErrorMessage("invalid token")
+		}
+		return err
+	}
+
+	if err := app.repo.SetEmailVerified(user.ID, true); err != nil {
+		return err
+	}
+	return app.repo.RevokeEmailVerificationTokenByUserID(user.ID)
+}
+
+func (app *app) ResendVerificationEmail(user *model.User) error {
+	if user.EmailVerified {
+		return ErrorMessage("your email is already verified")
+	}
+	return app.sendVerificationEmail(user.ID, user.Email)
+}
+
+// sendVerificationEmail issues a new verification token (older ones are revoked) and mails the link to the user.
+// mails are sent at most once per VerificationMailInterval for each user
+func (app *app) sendVerificationEmail(uid uint32, email string) error {
+	ok, err := app.repo.LockVerificationMail(uid, VerificationMailInterval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorMessage("verification email was sent recently. please wait a minute and try again")
+	}
+
+	if err := app.repo.RevokeEmailVerificationTokenByUserID(uid); err != nil {
+		return err
+	}
+	token, verification := app.newEmailVerificationToken()
+	if err := app.repo.NewEmailVerificationToken(uid, verification.TokenHash, uint64(verification.ExpiresAt)); err != nil {
+		return err
+	}
+	app.mailVerificationLink(email, token)
 	return nil
 }
+
+// newEmailVerificationToken returns the token for the mail and the record to store, which has only its hash
+func (app *app) newEmailVerificationToken() (string, *model.EmailVerificationToken) {
+	token := app.newToken()
+	return token, &model.EmailVerificationToken{
+		TokenHash: hashEmailVerificationToken(token),
+		ExpiresAt: time.Now().Add(EmailVerificationTokenLimit).Unix(),
+	}
+}
+
+// hashEmailVerificationToken returns the value stored in email_verification_tokens.token_hash
+func hashEmailVerificationToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func (app *app) mailVerificationLink(email, token string) {
+	link := fmt.Sprintf("%s/#/verify-email?token=%s", app.frontOrigin, url.QueryEscape(token))
+	go func() {
+		err := app.mailer.Send(email, "email verification", fmt.Sprintf("please open the following link to verify your email address:\n%s", link))
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+}
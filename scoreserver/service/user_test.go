@@ -1,7 +1,10 @@
 package service
 
 import (
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRegister(t *testing.T) {
@@ -30,7 +33,7 @@ func TestRegister(t *testing.T) {
 	}
 
 	for _, c := range testCases {
-		err := app.RegisterUserCreateTeam(c.username, c.email, c.password, c.teamname, c.countrycode)
+		err := app.RegisterUserCreateTeam(c.username, c.email, c.password, c.teamname, c.countrycode, "")
 		if c.hasError != (err != nil) {
 			t.Errorf("case %v, err: %v", c, err)
 		}
@@ -40,7 +43,7 @@ func TestRegister(t *testing.T) {
 func TestLogin(t *testing.T) {
 	app := newApp(t)
 
-	err := app.RegisterUserCreateTeam("testlogin", "testlogin@example.com", "password", "team-testlogin", "JPN")
+	err := app.RegisterUserCreateTeam("testlogin", "testlogin@example.com", "password", "team-testlogin", "JPN", "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -58,7 +61,7 @@ func TestLogin(t *testing.T) {
 		{"wrogusername", "password", true},
 	}
 	for _, c := range testCases {
-		_, _, err := app.LoginUser(c.username, c.password)
+		_, _, err := app.LoginUser(c.username, c.password, "", nil)
 		if c.hasError != (err != nil) {
 			t.Errorf("case %v, err: %v", c, err)
 		}
@@ -68,11 +71,11 @@ func TestLogin(t *testing.T) {
 func TestToken(t *testing.T) {
 	app := newApp(t)
 
-	err := app.RegisterUserCreateTeam("testtoken", "testtoken@example.com", "password", "team-testtoken", "JPN")
+	err := app.RegisterUserCreateTeam("testtoken", "testtoken@example.com", "password", "team-testtoken", "JPN", "")
 	if err != nil {
 		t.Error(err)
 	}
-	user, token, err := app.LoginUser("testtoken", "password")
+	user, token, err := app.LoginUser("testtoken", "password", "", nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -89,11 +92,11 @@ func TestToken(t *testing.T) {
 func TestLogout(t *testing.T) {
 	app := newApp(t)
 
-	err := app.RegisterUserCreateTeam("testlogout", "testlogout@example.com", "password", "team-testlogout", "JPN")
+	err := app.RegisterUserCreateTeam("testlogout", "testlogout@example.com", "password", "team-testlogout", "JPN", "")
 	if err != nil {
 		t.Error(err)
 	}
-	user, token, err := app.LoginUser("testlogout", "password")
+	user, token, err := app.LoginUser("testlogout", "password", "", nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -113,3 +116,256 @@ func TestLogout(t *testing.T) {
 		t.Error("logout failed")
 	}
 }
+
+func TestMaxTeamSize(t *testing.T) {
+	app := newApp(t)
+
+	if err := app.SetMaxTeamSize(1); err != nil {
+		t.Fatal(err)
+	}
+	defer app.SetMaxTeamSize(0)
+
+	err := app.RegisterUserCreateTeam("testteamsize1", "testteamsize1@example.com", "password", "team-testteamsize", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testteamsize1", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := app.GetUserTeam(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.JoinUserToTeam("testteamsize2", "testteamsize2@example.com", "password", team.Token, "")
+	if err == nil {
+		t.Error("joined to the full team")
+	}
+}
+
+func TestSoloMode(t *testing.T) {
+	app := newApp(t)
+
+	err := app.RegisterUserCreateTeam("testsolo0", "testsolo0@example.com", "password", "team-testsolo", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := app.LoginUser("testsolo0", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherTeam, err := app.GetUserTeam(other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.SetSoloMode(true); err != nil {
+		t.Fatal(err)
+	}
+	defer app.SetSoloMode(false)
+
+	err = app.RegisterUserCreateTeam("testsolo1", "testsolo1@example.com", "password", "ignored-teamname", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := app.LoginUser("testsolo1", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := app.GetUserTeam(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Teamname != "testsolo1" {
+		t.Errorf("team should be named after the user, but %s", team.Teamname)
+	}
+
+	if err := app.JoinUserToTeam("testsolo2", "testsolo2@example.com", "password", otherTeam.Token, ""); err == nil {
+		t.Error("joining a team should be disabled")
+	}
+	if err := app.UpdateTeamName(team.ID, "newname"); err == nil {
+		t.Error("teamname should not be changed")
+	}
+
+	if err := app.ChangeUsername(user, "password", "testsolo1-renamed"); err != nil {
+		t.Fatal(err)
+	}
+	team, err = app.GetUserTeam(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Teamname != "testsolo1-renamed" {
+		t.Errorf("team should follow the username, but %s", team.Teamname)
+	}
+
+	// usernames can be longer than teamnames
+	long := "testsolo3-" + strings.Repeat("x", 30)
+	err = app.RegisterUserCreateTeam(long, "testsolo3@example.com", "password", "", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	longUser, _, err := app.LoginUser(long, "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err = app.GetUserTeam(longUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Teamname != long[:TeamNameMaxLength] {
+		t.Errorf("team should be named after the cut username, but %s", team.Teamname)
+	}
+	// another user with the same first characters gets a suffix
+	err = app.RegisterUserCreateTeam(long+"y", "testsolo4@example.com", "password", "", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.ChangeUsername(longUser, "password", long+"z"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	mails := make(testMailer, 1)
+	app := newAppWithMailer(t, mails)
+
+	err := app.RegisterUserCreateTeam("testreset", "testreset@example.com", "password", "team-testreset", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, session, err := app.LoginUser("testreset", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.IssuePasswordResetToken("testreset-unknown@example.com", nil); err != nil {
+		t.Errorf("unknown email should not be an error: %v", err)
+	}
+	if err := app.IssuePasswordResetToken("testreset@example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var body string
+	select {
+	case body = <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reset mail was not sent")
+	}
+	link := body[strings.Index(body, "http://localhost:8080/#/reset?token="):]
+	link = link[:strings.Index(link, "\n")]
+	u, err := url.Parse(strings.Replace(link, "/#/", "/", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := u.Query().Get("token")
+
+	if _, err := app.GetLoginUser(session); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.ResetPassword(token, "newpassword"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.ResetPassword(token, "newpassword2"); err == nil {
+		t.Error("the token should be used only once")
+	}
+	if _, err := app.GetLoginUser(session); err == nil || !IsErrorMessage(err) {
+		t.Errorf("sessions should be revoked, err: %v", err)
+	}
+	if _, _, err := app.LoginUser("testreset", "newpassword", "", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmailVerificationExistingUsers(t *testing.T) {
+	mails := make(testMailer, 1)
+	app := newAppWithMailer(t, mails)
+	if err := app.SetEmailVerification(false); err != nil {
+		t.Fatal(err)
+	}
+	defer app.SetEmailVerification(false)
+
+	err := app.RegisterUserCreateTeam("testverifyold", "testverifyold@example.com", "password", "team-testverifyold", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SetEmailVerification(true); err != nil {
+		t.Fatal(err)
+	}
+	err = app.RegisterUserCreateTeam("testverifynew", "testverifynew@example.com", "password", "team-testverifynew", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("verification mail was not sent")
+	}
+
+	// enabling again must not verify the users who have not verified yet
+	if err := app.SetEmailVerification(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SetEmailVerification(true); err != nil {
+		t.Fatal(err)
+	}
+
+	old, _, err := app.LoginUser("testverifyold", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !old.EmailVerified {
+		t.Error("the user registered before the verification was enabled should be verified")
+	}
+	user, _, err := app.LoginUser("testverifynew", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified {
+		t.Error("the user registered while the verification is enabled should verify the email")
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mails := make(testMailer, 1)
+	app := newAppWithMailer(t, mails)
+	if err := app.SetEmailVerification(true); err != nil {
+		t.Fatal(err)
+	}
+	defer app.SetEmailVerification(false)
+
+	err := app.RegisterUserCreateTeam("testverify", "testverify@example.com", "password", "team-testverify", "JPN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body string
+	select {
+	case body = <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("verification mail was not sent")
+	}
+	link := body[strings.Index(body, "http://localhost:8080/#/verify-email?token="):]
+	u, err := url.Parse(strings.Replace(strings.TrimSpace(link), "/#/", "/", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := u.Query().Get("token")
+
+	// only the hash is stored, so the stored value does not verify the email
+	if err := app.VerifyEmail(hashEmailVerificationToken(token)); err == nil {
+		t.Error("the hash of the token should not be accepted")
+	}
+	if err := app.VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.VerifyEmail(token); err == nil {
+		t.Error("the token should be used only once")
+	}
+	user, _, err := app.LoginUser("testverify", "password", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.EmailVerified {
+		t.Error("the email should be verified")
+	}
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage keeps uploaded files such as icons.
+// names are flat (no directories) and chosen by the caller.
+type Storage interface {
+	Put(name string, data []byte) error
+	Get(name string) ([]byte, error)
+	Delete(name string) error
+}
+
+type localStorage struct {
+	dir string
+}
+
+// NewLocal returns a Storage which saves files into dir on the local disk
+func NewLocal(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &localStorage{
+		dir: dir,
+	}, nil
+}
+
+func (s *localStorage) path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid file name: %q", name)
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+func (s *localStorage) Put(name string, data []byte) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so that readers never see a half written file
+	tmp, err := ioutil.TempFile(s.dir, ".upload-")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (s *localStorage) Get(name string) ([]byte, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("%w", err)
+	}
+	return data, nil
+}
+
+func (s *localStorage) Delete(name string) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}